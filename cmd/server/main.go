@@ -54,10 +54,61 @@ func main() {
 		log.Fatalf("Failed to initialize FCM client: %v", err)
 	}
 
+	breakerWindow, err := time.ParseDuration(cfg.FCM.BreakerWindow)
+	if err != nil {
+		log.Fatalf("Invalid circuit breaker window: %v", err)
+	}
+
+	breakerOpenTimeout, err := time.ParseDuration(cfg.FCM.BreakerOpenTimeout)
+	if err != nil {
+		log.Fatalf("Invalid circuit breaker open timeout: %v", err)
+	}
+
+	fcmBreaker := fcm.NewCircuitBreaker(fcm.BreakerConfig{
+		Window:       breakerWindow,
+		MinRequests:  cfg.FCM.BreakerMinRequests,
+		FailureRatio: cfg.FCM.BreakerFailureRatio,
+		OpenTimeout:  breakerOpenTimeout,
+	})
+	fcmClient.UseCircuitBreaker(fcmBreaker)
+
 	queueRepo := repository.NewQueueRepository(db)
+	deviceRepo := repository.NewDeviceRepository(db)
+	tokenRepo := repository.NewTokenRepository(db)
+	topicRepo := repository.NewTopicRepository(db)
+
+	maxScheduleAhead, err := time.ParseDuration(cfg.Queue.MaxScheduleAhead)
+	if err != nil {
+		log.Fatalf("Invalid max schedule ahead: %v", err)
+	}
+
+	sendAtTolerance, err := time.ParseDuration(cfg.Queue.SendAtTolerance)
+	if err != nil {
+		log.Fatalf("Invalid send_at tolerance: %v", err)
+	}
+
+	idempotencyRetention, err := time.ParseDuration(cfg.Queue.IdempotencyRetention)
+	if err != nil {
+		log.Fatalf("Invalid idempotency retention: %v", err)
+	}
+
+	contentDedupWindow, err := time.ParseDuration(cfg.Queue.ContentDedupWindow)
+	if err != nil {
+		log.Fatalf("Invalid content dedup window: %v", err)
+	}
 
 	pushService := service.NewPushService(fcmClient)
-	queueService := service.NewQueueService(queueRepo)
+	queueService := service.NewQueueService(queueRepo, tokenRepo, fcmBreaker, service.QueueConfig{
+		MaxScheduleAhead:            maxScheduleAhead,
+		SendAtTolerance:             sendAtTolerance,
+		IdempotencyRetention:        idempotencyRetention,
+		ContentDedupWindow:          contentDedupWindow,
+		ContentDedupDisabledClients: parseList(cfg.Queue.ContentDedupDisabledClients),
+	})
+	deadLetterService := service.NewDeadLetterService(queueRepo)
+	deviceService := service.NewDeviceService(deviceRepo, queueService)
+	tokenService := service.NewTokenService(tokenRepo)
+	topicService := service.NewTopicService(fcmClient, topicRepo)
 
 	pollInterval, err := time.ParseDuration(cfg.Worker.PollInterval)
 	if err != nil {
@@ -69,17 +120,68 @@ func main() {
 		log.Fatalf("Invalid retry intervals: %v", err)
 	}
 
-	queueWorker := worker.NewQueueWorker(queueRepo, fcmClient, worker.Config{
-		WorkerCount:    cfg.Worker.WorkerCount,
-		PollInterval:   pollInterval,
-		RetryIntervals: retryIntervals,
-		CleanupAfter:   time.Duration(cfg.Worker.CleanupAfterDays) * 24 * time.Hour,
+	backoffBase, err := time.ParseDuration(cfg.Worker.BackoffBase)
+	if err != nil {
+		log.Fatalf("Invalid retry backoff base: %v", err)
+	}
+
+	backoffMax, err := time.ParseDuration(cfg.Worker.BackoffMax)
+	if err != nil {
+		log.Fatalf("Invalid retry backoff max: %v", err)
+	}
+
+	clientRetryPolicies, err := parseMapping(cfg.Worker.RetryPolicyClients)
+	if err != nil {
+		log.Fatalf("Invalid client retry policies: %v", err)
+	}
+
+	leaseDuration, err := time.ParseDuration(cfg.Worker.LeaseDuration)
+	if err != nil {
+		log.Fatalf("Invalid lease duration: %v", err)
+	}
+
+	reaperInterval, err := time.ParseDuration(cfg.Worker.ReaperInterval)
+	if err != nil {
+		log.Fatalf("Invalid reaper interval: %v", err)
+	}
+
+	latencyTarget, err := time.ParseDuration(cfg.Worker.LatencyTarget)
+	if err != nil {
+		log.Fatalf("Invalid latency target: %v", err)
+	}
+
+	drainTimeout, err := time.ParseDuration(cfg.Worker.DrainTimeout)
+	if err != nil {
+		log.Fatalf("Invalid drain timeout: %v", err)
+	}
+
+	queueWorker := worker.NewQueueWorker(queueRepo, tokenRepo, fcmClient, worker.Config{
+		WorkerCount:         cfg.Worker.WorkerCount,
+		HighPriorityWorkers: cfg.Worker.HighPriorityWorkers,
+		PollInterval:        pollInterval,
+		RetryIntervals:      retryIntervals,
+		BackoffBase:         backoffBase,
+		BackoffMax:          backoffMax,
+		DefaultRetryPolicy:  cfg.Worker.RetryPolicy,
+		ClientRetryPolicies: clientRetryPolicies,
+		CleanupAfter:        time.Duration(cfg.Worker.CleanupAfterDays) * 24 * time.Hour,
+		DeadLetterRetention: time.Duration(cfg.Worker.DLQRetentionDays) * 24 * time.Hour,
+		LeaseDuration:       leaseDuration,
+		ReaperInterval:      reaperInterval,
+		BatchSize:           cfg.Worker.BatchSize,
+		MinConcurrency:      cfg.Worker.MinConcurrency,
+		MaxConcurrency:      cfg.Worker.MaxConcurrency,
+		LatencyTarget:       latencyTarget,
+		MaxInFlight:         cfg.Worker.MaxInFlight,
 	})
 	queueWorker.Start()
-	defer queueWorker.Stop()
 
-	pushHandler := handler.NewPushHandler(pushService, queueService)
+	pushHandler := handler.NewPushHandler(pushService, queueService, deviceService)
 	queueHandler := handler.NewQueueHandler(queueService)
+	deadLetterHandler := handler.NewDeadLetterHandler(deadLetterService)
+	deviceHandler := handler.NewDeviceHandler(deviceService)
+	tokenHandler := handler.NewTokenHandler(tokenService)
+	topicHandler := handler.NewTopicHandler(topicService)
 
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.New()
@@ -88,6 +190,7 @@ func main() {
 	router.Use(middleware.CORSMiddleware())
 
 	router.GET("/health", pushHandler.HealthCheck)
+	router.GET("/ready", pushHandler.ReadinessCheck)
 
 	api := router.Group("/api/v1")
 	api.Use(middleware.AuthMiddleware())
@@ -96,6 +199,8 @@ func main() {
 		{
 			push.POST("/send", pushHandler.SendPush)
 			push.POST("/send-batch", pushHandler.SendBatchPush)
+			push.POST("/multicast", pushHandler.SendMulticast)
+			push.POST("/raw", pushHandler.SendRaw)
 		}
 
 		queue := api.Group("/queue")
@@ -103,6 +208,38 @@ func main() {
 			queue.GET("/status/:id", queueHandler.GetTaskStatus)
 			queue.GET("/history", queueHandler.GetHistory)
 			queue.GET("/stats", queueHandler.GetStats)
+			queue.DELETE("/tasks/:id", queueHandler.CancelTask)
+			queue.PATCH("/tasks/:id", queueHandler.UpdateTask)
+			queue.POST("/tasks/:id/retry", queueHandler.RetryTask)
+			queue.GET("/tasks/:id/timeline", queueHandler.GetTaskTimeline)
+			queue.GET("/groups/:id", queueHandler.GetSendGroup)
+			queue.POST("/retry", queueHandler.RetryFailedTasks)
+		}
+
+		devices := api.Group("/devices")
+		{
+			devices.POST("", deviceHandler.Register)
+			devices.GET("", deviceHandler.List)
+			devices.DELETE("", deviceHandler.DeleteByToken)
+			devices.DELETE("/:id", deviceHandler.Delete)
+		}
+
+		api.GET("/tokens/invalid", tokenHandler.ListInvalid)
+		api.GET("/tokens/topics", topicHandler.ListForToken)
+
+		topics := api.Group("/topics")
+		{
+			topics.POST("/:topic/subscribe", topicHandler.Subscribe)
+			topics.POST("/:topic/unsubscribe", topicHandler.Unsubscribe)
+		}
+
+		dlq := api.Group("/dlq")
+		{
+			dlq.GET("", deadLetterHandler.List)
+			dlq.DELETE("", deadLetterHandler.Purge)
+			dlq.GET("/:id", deadLetterHandler.Get)
+			dlq.POST("/:id/requeue", deadLetterHandler.Requeue)
+			dlq.DELETE("/:id", deadLetterHandler.Delete)
 		}
 	}
 
@@ -124,6 +261,12 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
+	// Fail readiness first so no new traffic is routed here, then let the
+	// worker finish what it holds before the HTTP server goes away.
+	log.Println("Draining...")
+	pushHandler.StartDraining()
+	queueWorker.Drain(drainTimeout)
+
 	log.Println("Shutting down server...")
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -178,3 +321,26 @@ func parseRetryIntervals(intervalsStr string) ([]time.Duration, error) {
 
 	return intervals, nil
 }
+
+func parseList(listStr string) []string {
+	var items []string
+	for _, part := range strings.Split(listStr, ",") {
+		if item := strings.TrimSpace(part); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseMapping parses "key:value,key:value" pairs.
+func parseMapping(mappingStr string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	for _, item := range parseList(mappingStr) {
+		key, value, ok := strings.Cut(item, ":")
+		if !ok || strings.TrimSpace(key) == "" || strings.TrimSpace(value) == "" {
+			return nil, fmt.Errorf("invalid mapping %q, expected key:value", item)
+		}
+		mapping[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return mapping, nil
+}
@@ -11,6 +11,7 @@ type Config struct {
 	FCM      FCMConfig
 	Database DatabaseConfig
 	Worker   WorkerConfig
+	Queue    QueueConfig
 }
 type ServerConfig struct {
 	Port         string
@@ -18,8 +19,12 @@ type ServerConfig struct {
 	WriteTimeout int
 }
 type FCMConfig struct {
-	CredentialsPath string
-	ProjectID       string
+	CredentialsPath     string
+	ProjectID           string
+	BreakerWindow       string
+	BreakerMinRequests  int
+	BreakerFailureRatio float64
+	BreakerOpenTimeout  string
 }
 
 type DatabaseConfig struct {
@@ -32,11 +37,33 @@ type DatabaseConfig struct {
 }
 
 type WorkerConfig struct {
-	WorkerCount      int
-	PollInterval     string
-	MaxRetryAttempts int
-	RetryIntervals   string
-	CleanupAfterDays int
+	WorkerCount         int
+	HighPriorityWorkers int
+	PollInterval        string
+	MaxRetryAttempts    int
+	RetryIntervals      string
+	RetryPolicy         string
+	RetryPolicyClients  string
+	BackoffBase         string
+	BackoffMax          string
+	CleanupAfterDays    int
+	DLQRetentionDays    int
+	LeaseDuration       string
+	ReaperInterval      string
+	DrainTimeout        string
+	BatchSize           int
+	MinConcurrency      int
+	MaxConcurrency      int
+	LatencyTarget       string
+	MaxInFlight         int
+}
+
+type QueueConfig struct {
+	MaxScheduleAhead            string
+	SendAtTolerance             string
+	IdempotencyRetention        string
+	ContentDedupWindow          string
+	ContentDedupDisabledClients string
 }
 
 func Load() (*Config, error) {
@@ -47,8 +74,12 @@ func Load() (*Config, error) {
 			WriteTimeout: getEnvAsInt("SERVER_WRITE_TIMEOUT", 10),
 		},
 		FCM: FCMConfig{
-			CredentialsPath: getEnv("FCM_CREDENTIALS_PATH", ""),
-			ProjectID:       getEnv("FCM_PROJECT_ID", ""),
+			CredentialsPath:     getEnv("FCM_CREDENTIALS_PATH", ""),
+			ProjectID:           getEnv("FCM_PROJECT_ID", ""),
+			BreakerWindow:       getEnv("FCM_BREAKER_WINDOW", "30s"),
+			BreakerMinRequests:  getEnvAsInt("FCM_BREAKER_MIN_REQUESTS", 20),
+			BreakerFailureRatio: getEnvAsFloat("FCM_BREAKER_FAILURE_RATIO", 0.5),
+			BreakerOpenTimeout:  getEnv("FCM_BREAKER_OPEN_TIMEOUT", "30s"),
 		},
 		Database: DatabaseConfig{
 			Host:     getEnv("DB_HOST", "localhost"),
@@ -59,11 +90,32 @@ func Load() (*Config, error) {
 			SSLMode:  getEnv("DB_SSL_MODE", "disable"),
 		},
 		Worker: WorkerConfig{
-			WorkerCount:      getEnvAsInt("WORKER_COUNT", 5),
-			PollInterval:     getEnv("WORKER_POLL_INTERVAL", "5s"),
-			MaxRetryAttempts: getEnvAsInt("MAX_RETRY_ATTEMPTS", 3),
-			RetryIntervals:   getEnv("RETRY_INTERVALS", "1m,5m,15m"),
-			CleanupAfterDays: getEnvAsInt("CLEANUP_AFTER_DAYS", 30),
+			WorkerCount:         getEnvAsInt("WORKER_COUNT", 5),
+			HighPriorityWorkers: getEnvAsInt("WORKER_HIGH_PRIORITY_COUNT", 1),
+			PollInterval:        getEnv("WORKER_POLL_INTERVAL", "15s"),
+			MaxRetryAttempts:    getEnvAsInt("MAX_RETRY_ATTEMPTS", 3),
+			RetryIntervals:      getEnv("RETRY_INTERVALS", "1m,5m,15m"),
+			RetryPolicy:         getEnv("RETRY_POLICY", "fixed"),
+			RetryPolicyClients:  getEnv("RETRY_POLICY_CLIENTS", ""),
+			BackoffBase:         getEnv("RETRY_BACKOFF_BASE", "30s"),
+			BackoffMax:          getEnv("RETRY_BACKOFF_MAX", "30m"),
+			CleanupAfterDays:    getEnvAsInt("CLEANUP_AFTER_DAYS", 30),
+			DLQRetentionDays:    getEnvAsInt("DLQ_RETENTION_DAYS", 90),
+			LeaseDuration:       getEnv("WORKER_LEASE_DURATION", "2m"),
+			ReaperInterval:      getEnv("WORKER_REAPER_INTERVAL", "30s"),
+			DrainTimeout:        getEnv("WORKER_DRAIN_TIMEOUT", "30s"),
+			BatchSize:           getEnvAsInt("WORKER_BATCH_SIZE", 100),
+			MinConcurrency:      getEnvAsInt("WORKER_MIN_CONCURRENCY", 1),
+			MaxConcurrency:      getEnvAsInt("WORKER_MAX_CONCURRENCY", 50),
+			LatencyTarget:       getEnv("WORKER_LATENCY_TARGET", "1s"),
+			MaxInFlight:         getEnvAsInt("FCM_MAX_IN_FLIGHT", 200),
+		},
+		Queue: QueueConfig{
+			MaxScheduleAhead:            getEnv("QUEUE_MAX_SCHEDULE_AHEAD", "720h"),
+			SendAtTolerance:             getEnv("QUEUE_SEND_AT_TOLERANCE", "5m"),
+			IdempotencyRetention:        getEnv("QUEUE_IDEMPOTENCY_RETENTION", "24h"),
+			ContentDedupWindow:          getEnv("QUEUE_CONTENT_DEDUP_WINDOW", "10s"),
+			ContentDedupDisabledClients: getEnv("QUEUE_CONTENT_DEDUP_DISABLED_CLIENTS", ""),
 		},
 	}
 
@@ -94,3 +146,11 @@ func getEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	valueStr := os.Getenv(key)
+	if value, err := strconv.ParseFloat(valueStr, 64); err == nil {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/galyym/fcm_push/internal/model"
+	"github.com/galyym/fcm_push/internal/repository"
+	"github.com/galyym/fcm_push/internal/service"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type DeadLetterHandler struct {
+	deadLetterService *service.DeadLetterService
+}
+
+func NewDeadLetterHandler(deadLetterService *service.DeadLetterService) *DeadLetterHandler {
+	return &DeadLetterHandler{
+		deadLetterService: deadLetterService,
+	}
+}
+
+func (h *DeadLetterHandler) List(c *gin.Context) {
+	var req model.DeadLetterListRequest
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid query parameters",
+		})
+		return
+	}
+
+	entries, err := h.deadLetterService.List(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to retrieve dead letters",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+func (h *DeadLetterHandler) Get(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid task ID format",
+		})
+		return
+	}
+
+	entry, err := h.deadLetterService.Get(c.Request.Context(), id)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, entry)
+}
+
+func (h *DeadLetterHandler) Requeue(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid task ID format",
+		})
+		return
+	}
+
+	task, err := h.deadLetterService.Requeue(c.Request.Context(), id)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, task)
+}
+
+func (h *DeadLetterHandler) Delete(c *gin.Context) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid task ID format",
+		})
+		return
+	}
+
+	if err := h.deadLetterService.Delete(c.Request.Context(), id); err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *DeadLetterHandler) Purge(c *gin.Context) {
+	var req model.DeadLetterPurgeRequest
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid query parameters",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	result, err := h.deadLetterService.Purge(c.Request.Context(), &req)
+	if err != nil {
+		h.respondError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func (h *DeadLetterHandler) respondError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repository.ErrDeadLetterNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Dead letter not found",
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to process dead letter",
+		})
+	}
+}
@@ -1,28 +1,34 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
+	"sync/atomic"
 
 	"github.com/galyym/fcm_push/internal/model"
 	"github.com/galyym/fcm_push/internal/service"
+	"github.com/galyym/fcm_push/pkg/fcm"
 	"github.com/gin-gonic/gin"
 )
 
 type PushHandler struct {
-	pushService  *service.PushService
-	queueService *service.QueueService
+	pushService   *service.PushService
+	queueService  *service.QueueService
+	deviceService *service.DeviceService
+	draining      atomic.Bool
 }
 
-func NewPushHandler(pushService *service.PushService, queueService *service.QueueService) *PushHandler {
+func NewPushHandler(pushService *service.PushService, queueService *service.QueueService, deviceService *service.DeviceService) *PushHandler {
 	return &PushHandler{
-		pushService:  pushService,
-		queueService: queueService,
+		pushService:   pushService,
+		queueService:  queueService,
+		deviceService: deviceService,
 	}
 }
 
 // SendPush обрабатывает запрос на отправку одного push-уведомления
 // @Summary Отправить push-уведомление
-// @Description Отправляет push-уведомление на указанное устройство
+// @Description Отправляет push-уведомление на устройство, всем устройствам пользователя, в топик или по условию
 // @Tags push
 // @Accept json
 // @Produce json
@@ -46,17 +52,55 @@ func (h *PushHandler) SendPush(c *gin.Context) {
 		req.Priority = "normal"
 	}
 
+	if !bindIdempotencyKey(c, &req.IdempotencyKey) {
+		return
+	}
+
 	// Enqueue push notification instead of sending directly
 	queueReq := &model.CreateQueueTaskRequest{
-		Token:    req.Token,
-		Title:    req.Title,
-		Body:     req.Body,
-		Data:     req.Data,
-		Priority: req.Priority,
-		ClientID: req.ClientID,
+		Token:          req.Token,
+		Topic:          req.Topic,
+		Condition:      req.Condition,
+		MessageType:    req.Type,
+		Title:          req.Title,
+		Body:           req.Body,
+		Data:           req.Data,
+		Platform:       model.NewPlatformOptions(req.Android, req.APNS, req.Webpush),
+		Priority:       req.Priority,
+		TTL:            req.TTL,
+		ExpiresAt:      req.ExpiresAt,
+		CollapseKey:    req.CollapseKey,
+		ClientID:       req.ClientID,
+		SendAt:         req.SendAt,
+		IdempotencyKey: req.IdempotencyKey,
+		RetryPolicy:    req.RetryPolicy,
 	}
 
+	if req.UserID != "" {
+		h.sendToUser(c, req.UserID, queueReq)
+		return
+	}
+
+	h.enqueue(c, queueReq)
+}
+
+// enqueue ставит одну задачу в очередь и отвечает её статусом
+func (h *PushHandler) enqueue(c *gin.Context, queueReq *model.CreateQueueTaskRequest) {
 	task, err := h.queueService.EnqueuePush(c.Request.Context(), queueReq)
+	if errors.Is(err, service.ErrInvalidRequest) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, service.ErrTokenInvalid) {
+		c.JSON(http.StatusUnprocessableEntity, ErrorResponse{
+			Error:   "Token is invalid",
+			Message: err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, ErrorResponse{
 			Error:   "Failed to enqueue push",
@@ -65,13 +109,58 @@ func (h *PushHandler) SendPush(c *gin.Context) {
 		return
 	}
 
+	if task.Duplicate {
+		c.JSON(http.StatusOK, gin.H{
+			"queue_task_id": task.ID,
+			"status":        task.Status,
+			"scheduled_at":  task.ScheduledAt,
+			"duplicate":     true,
+			"message":       "Duplicate request, returning the existing task",
+		})
+		return
+	}
+
 	c.JSON(http.StatusAccepted, gin.H{
 		"queue_task_id": task.ID,
 		"status":        task.Status,
+		"scheduled_at":  task.ScheduledAt,
 		"message":       "Push notification queued successfully",
 	})
 }
 
+// sendToUser ставит уведомление в очередь для всех активных устройств пользователя
+func (h *PushHandler) sendToUser(c *gin.Context, userID string, queueReq *model.CreateQueueTaskRequest) {
+	tasks, err := h.deviceService.EnqueueForUser(c.Request.Context(), userID, queueReq)
+	if errors.Is(err, service.ErrInvalidRequest) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, service.ErrNoActiveDevices) {
+		c.JSON(http.StatusNotFound, ErrorResponse{
+			Error:   "No active devices",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to enqueue push",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"user_id":      userID,
+		"queued_count": len(tasks),
+		"tasks":        tasks,
+		"message":      "Push notification queued for all active devices",
+	})
+}
+
 // SendBatchPush обрабатывает запрос на отправку нескольких push-уведомлений
 // @Summary Отправить batch push-уведомлений
 // @Description Отправляет несколько push-уведомлений за один запрос
@@ -96,13 +185,31 @@ func (h *PushHandler) SendBatchPush(c *gin.Context) {
 
 	queueTasks := make([]model.CreateQueueTaskRequest, len(req.Notifications))
 	for i, notification := range req.Notifications {
+		if notification.UserID != "" {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid request",
+				Message: "user_id is only supported by /api/v1/push/send",
+			})
+			return
+		}
+
 		queueTasks[i] = model.CreateQueueTaskRequest{
-			Token:    notification.Token,
-			Title:    notification.Title,
-			Body:     notification.Body,
-			Data:     notification.Data,
-			Priority: notification.Priority,
-			ClientID: notification.ClientID,
+			Token:          notification.Token,
+			Topic:          notification.Topic,
+			Condition:      notification.Condition,
+			MessageType:    notification.Type,
+			Title:          notification.Title,
+			Body:           notification.Body,
+			Data:           notification.Data,
+			Platform:       model.NewPlatformOptions(notification.Android, notification.APNS, notification.Webpush),
+			Priority:       notification.Priority,
+			TTL:            notification.TTL,
+			ExpiresAt:      notification.ExpiresAt,
+			CollapseKey:    notification.CollapseKey,
+			ClientID:       notification.ClientID,
+			SendAt:         notification.SendAt,
+			IdempotencyKey: notification.IdempotencyKey,
+			RetryPolicy:    notification.RetryPolicy,
 		}
 	}
 
@@ -122,6 +229,126 @@ func (h *PushHandler) SendBatchPush(c *gin.Context) {
 	})
 }
 
+// bindIdempotencyKey подставляет ключ из заголовка Idempotency-Key; при расхождении с полем запроса отвечает 400
+func bindIdempotencyKey(c *gin.Context, key *string) bool {
+	headerKey := c.GetHeader("Idempotency-Key")
+	if headerKey == "" {
+		return true
+	}
+
+	if *key != "" && *key != headerKey {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request",
+			Message: "Idempotency-Key header and idempotency_key field differ",
+		})
+		return false
+	}
+	*key = headerKey
+	return true
+}
+
+// SendMulticast ставит одно уведомление в очередь для списка токенов
+// @Summary Отправить multicast push-уведомление
+// @Description Ставит одно уведомление в очередь для каждого уникального токена и объединяет задачи в группу
+// @Tags push
+// @Accept json
+// @Produce json
+// @Param request body model.MulticastPushRequest true "Multicast request"
+// @Success 202 {object} model.SendGroupResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/push/multicast [post]
+func (h *PushHandler) SendMulticast(c *gin.Context) {
+	var req model.MulticastPushRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if req.Priority == "" {
+		req.Priority = "normal"
+	}
+
+	if !bindIdempotencyKey(c, &req.IdempotencyKey) {
+		return
+	}
+
+	group, err := h.queueService.EnqueueMulticast(c.Request.Context(), &req)
+	if errors.Is(err, service.ErrInvalidRequest) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+	if errors.Is(err, service.ErrTokenInvalid) {
+		c.JSON(http.StatusUnprocessableEntity, ErrorResponse{
+			Error:   "Tokens are invalid",
+			Message: err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to enqueue multicast",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if group.Duplicate {
+		c.JSON(http.StatusOK, group)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, group)
+}
+
+// SendRaw ставит в очередь готовое сообщение FCM HTTP v1
+// @Summary Отправить сообщение FCM HTTP v1
+// @Description Ставит в очередь объект message из FCM HTTP v1 API и отправляет его без изменений
+// @Tags push
+// @Accept json
+// @Produce json
+// @Param request body model.RawPushRequest true "Raw push request"
+// @Success 202 {object} model.QueueTaskResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/push/raw [post]
+func (h *PushHandler) SendRaw(c *gin.Context) {
+	var req model.RawPushRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request",
+			Message: err.Error(),
+		})
+		return
+	}
+
+	if req.Priority == "" {
+		req.Priority = "normal"
+	}
+
+	if !bindIdempotencyKey(c, &req.IdempotencyKey) {
+		return
+	}
+
+	h.enqueue(c, &model.CreateQueueTaskRequest{
+		MessageType:    model.MessageTypeRaw,
+		RawMessage:     req.Message,
+		Priority:       req.Priority,
+		ClientID:       req.ClientID,
+		SendAt:         req.SendAt,
+		IdempotencyKey: req.IdempotencyKey,
+		RetryPolicy:    req.RetryPolicy,
+	})
+}
+
 // HealthCheck проверка здоровья сервиса
 // @Summary Health check
 // @Description Проверка работоспособности сервиса
@@ -130,6 +357,43 @@ func (h *PushHandler) SendBatchPush(c *gin.Context) {
 // @Success 200 {object} HealthResponse
 // @Router /health [get]
 func (h *PushHandler) HealthCheck(c *gin.Context) {
+	response := HealthResponse{
+		Status:  "ok",
+		Service: "fcm-push-service",
+	}
+
+	if circuit := h.pushService.CircuitStatus(); circuit != nil {
+		response.FCMCircuit = circuit.State
+		if circuit.State != string(fcm.BreakerClosed) {
+			response.Status = "degraded"
+		}
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// StartDraining переводит сервис в режим завершения: /ready начинает отвечать 503
+func (h *PushHandler) StartDraining() {
+	h.draining.Store(true)
+}
+
+// ReadinessCheck проверка готовности принимать трафик
+// @Summary Readiness check
+// @Description Возвращает 503, когда сервис завершает работу
+// @Tags health
+// @Produce json
+// @Success 200 {object} HealthResponse
+// @Failure 503 {object} HealthResponse
+// @Router /ready [get]
+func (h *PushHandler) ReadinessCheck(c *gin.Context) {
+	if h.draining.Load() {
+		c.JSON(http.StatusServiceUnavailable, HealthResponse{
+			Status:  "draining",
+			Service: "fcm-push-service",
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, HealthResponse{
 		Status:  "ok",
 		Service: "fcm-push-service",
@@ -141,6 +405,7 @@ type ErrorResponse struct {
 	Message string `json:"message"`
 }
 type HealthResponse struct {
-	Status  string `json:"status"`
-	Service string `json:"service"`
+	Status     string `json:"status"`
+	Service    string `json:"service"`
+	FCMCircuit string `json:"fcm_circuit,omitempty"`
 }
@@ -1,9 +1,11 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/galyym/fcm_push/internal/model"
+	"github.com/galyym/fcm_push/internal/repository"
 	"github.com/galyym/fcm_push/internal/service"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -41,6 +43,58 @@ func (h *QueueHandler) GetTaskStatus(c *gin.Context) {
 	c.JSON(http.StatusOK, task)
 }
 
+func (h *QueueHandler) GetTaskTimeline(c *gin.Context) {
+	taskID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid task ID format",
+		})
+		return
+	}
+
+	timeline, err := h.queueService.GetTaskTimeline(c.Request.Context(), taskID)
+	if err != nil {
+		if errors.Is(err, repository.ErrTaskNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Task not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to retrieve timeline",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, timeline)
+}
+
+func (h *QueueHandler) GetSendGroup(c *gin.Context) {
+	groupID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid group ID format",
+		})
+		return
+	}
+
+	group, err := h.queueService.GetSendGroup(c.Request.Context(), groupID)
+	if err != nil {
+		if errors.Is(err, repository.ErrSendGroupNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Send group not found",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to retrieve send group",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, group)
+}
+
 func (h *QueueHandler) GetHistory(c *gin.Context) {
 	var req model.QueueHistoryRequest
 
@@ -73,3 +127,121 @@ func (h *QueueHandler) GetStats(c *gin.Context) {
 
 	c.JSON(http.StatusOK, stats)
 }
+
+func (h *QueueHandler) CancelTask(c *gin.Context) {
+	taskID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid task ID format",
+		})
+		return
+	}
+
+	task, err := h.queueService.CancelTask(c.Request.Context(), taskID)
+	if err != nil {
+		h.respondTaskUpdateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, task)
+}
+
+func (h *QueueHandler) UpdateTask(c *gin.Context) {
+	taskID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid task ID format",
+		})
+		return
+	}
+
+	var req model.UpdateQueueTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	if req.IsEmpty() {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": "at least one of send_at, priority, title, body or data is required",
+		})
+		return
+	}
+
+	task, err := h.queueService.UpdateTask(c.Request.Context(), taskID, &req)
+	if err != nil {
+		h.respondTaskUpdateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, task)
+}
+
+func (h *QueueHandler) RetryTask(c *gin.Context) {
+	taskID, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "Invalid task ID format",
+		})
+		return
+	}
+
+	task, err := h.queueService.RetryTask(c.Request.Context(), taskID)
+	if err != nil {
+		h.respondTaskUpdateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, task)
+}
+
+func (h *QueueHandler) RetryFailedTasks(c *gin.Context) {
+	var req model.BulkRetryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	result, err := h.queueService.RetryFailedTasks(c.Request.Context(), &req)
+	if err != nil {
+		h.respondTaskUpdateError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func (h *QueueHandler) respondTaskUpdateError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repository.ErrTaskNotFound):
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Task not found",
+		})
+	case errors.Is(err, repository.ErrTaskNotPending):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Task can only be changed while pending",
+			"message": err.Error(),
+		})
+	case errors.Is(err, repository.ErrTaskNotFailed):
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "Only failed tasks can be retried",
+			"message": err.Error(),
+		})
+	case errors.Is(err, service.ErrInvalidRequest):
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update task",
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/galyym/fcm_push/internal/model"
+	"github.com/galyym/fcm_push/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+type TokenHandler struct {
+	tokenService *service.TokenService
+}
+
+func NewTokenHandler(tokenService *service.TokenService) *TokenHandler {
+	return &TokenHandler{
+		tokenService: tokenService,
+	}
+}
+
+func (h *TokenHandler) ListInvalid(c *gin.Context) {
+	var req model.InvalidTokensRequest
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid query parameters",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	tokens, err := h.tokenService.ListInvalid(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to retrieve invalid tokens",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
@@ -0,0 +1,80 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/galyym/fcm_push/internal/model"
+	"github.com/galyym/fcm_push/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+type TopicHandler struct {
+	topicService *service.TopicService
+}
+
+func NewTopicHandler(topicService *service.TopicService) *TopicHandler {
+	return &TopicHandler{
+		topicService: topicService,
+	}
+}
+
+func (h *TopicHandler) Subscribe(c *gin.Context) {
+	h.manage(c, h.topicService.Subscribe, "Failed to subscribe to topic")
+}
+
+func (h *TopicHandler) Unsubscribe(c *gin.Context) {
+	h.manage(c, h.topicService.Unsubscribe, "Failed to unsubscribe from topic")
+}
+
+type topicOperation func(ctx context.Context, topic string, tokens []string) (*model.TopicManagementResponse, error)
+
+func (h *TopicHandler) manage(c *gin.Context, operation topicOperation, failureMessage string) {
+	var req model.TopicTokensRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	result, err := operation(c.Request.Context(), c.Param("topic"), req.Tokens)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrInvalidRequest) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
+			"error":   failureMessage,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func (h *TopicHandler) ListForToken(c *gin.Context) {
+	var req model.TokenTopicsRequest
+
+	if err := c.ShouldBindQuery(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid query parameters",
+			"message": err.Error(),
+		})
+		return
+	}
+
+	topics, err := h.topicService.TopicsForToken(c.Request.Context(), req.Token)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to retrieve topics",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, topics)
+}
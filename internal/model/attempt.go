@@ -0,0 +1,49 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	AttemptSuccess      = "success"
+	AttemptRetry        = "retry"
+	AttemptFailed       = "failed"
+	AttemptRequeued     = "requeued"
+	AttemptLeaseExpired = "lease_expired"
+	AttemptExpired      = "expired"
+)
+
+// PushAttempt records one send of a task by a worker.
+type PushAttempt struct {
+	TaskID       uuid.UUID `db:"task_id" json:"task_id"`
+	Attempt      int       `db:"attempt" json:"attempt"`
+	WorkerID     string    `db:"worker_id" json:"worker_id"`
+	StartedAt    time.Time `db:"started_at" json:"started_at"`
+	FinishedAt   time.Time `db:"finished_at" json:"finished_at"`
+	LatencyMs    int64     `db:"latency_ms" json:"latency_ms"`
+	Outcome      string    `db:"outcome" json:"outcome"`
+	ErrorCode    *string   `db:"error_code" json:"error_code,omitempty"`
+	ErrorMessage *string   `db:"error_message" json:"error_message,omitempty"`
+	FCMMessageID *string   `db:"fcm_message_id" json:"fcm_message_id,omitempty"`
+}
+
+// TaskEvent is one state change in a task's timeline.
+type TaskEvent struct {
+	At           time.Time   `json:"at"`
+	Event        string      `json:"event"`
+	Status       QueueStatus `json:"status"`
+	Attempt      int         `json:"attempt,omitempty"`
+	WorkerID     string      `json:"worker_id,omitempty"`
+	LatencyMs    *int64      `json:"latency_ms,omitempty"`
+	ErrorCode    *string     `json:"error_code,omitempty"`
+	ErrorMessage *string     `json:"error_message,omitempty"`
+	FCMMessageID *string     `json:"fcm_message_id,omitempty"`
+}
+
+type TaskTimelineResponse struct {
+	TaskID uuid.UUID   `json:"task_id"`
+	Status QueueStatus `json:"status"`
+	Events []TaskEvent `json:"events"`
+}
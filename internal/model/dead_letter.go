@@ -0,0 +1,66 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DeadLetterEntry is a snapshot of a task taken when it failed for good.
+// Its ID is the ID of the original push_queue task.
+type DeadLetterEntry struct {
+	ID             uuid.UUID        `db:"id" json:"id"`
+	TargetType     string           `db:"target_type" json:"target_type"`
+	Token          string           `db:"token" json:"token,omitempty"`
+	Topic          string           `db:"topic" json:"topic,omitempty"`
+	Condition      string           `db:"condition" json:"condition,omitempty"`
+	MessageType    string           `db:"message_type" json:"message_type"`
+	Title          string           `db:"title" json:"title,omitempty"`
+	Body           string           `db:"body" json:"body,omitempty"`
+	Data           JSONMap          `db:"data" json:"data,omitempty"`
+	Platform       *PlatformOptions `db:"platform_options" json:"platform_options,omitempty"`
+	RawMessage     json.RawMessage  `db:"raw_message" json:"raw_message,omitempty"`
+	Priority       string           `db:"priority" json:"priority"`
+	ExpiresAt      *time.Time       `db:"expires_at" json:"expires_at,omitempty"`
+	CollapseKey    string           `db:"collapse_key" json:"collapse_key,omitempty"`
+	ClientID       string           `db:"client_id" json:"client_id,omitempty"`
+	Attempts       int              `db:"attempts" json:"attempts"`
+	MaxAttempts    int              `db:"max_attempts" json:"max_attempts"`
+	RetryPolicy    *string          `db:"retry_policy" json:"retry_policy,omitempty"`
+	ErrorMessage   *string          `db:"error_message" json:"error_message,omitempty"`
+	ErrorCode      *string          `db:"error_code" json:"error_code,omitempty"`
+	SendAt         *time.Time       `db:"send_at" json:"send_at,omitempty"`
+	TaskCreatedAt  time.Time        `db:"task_created_at" json:"task_created_at"`
+	DeadLetteredAt time.Time        `db:"dead_lettered_at" json:"dead_lettered_at"`
+
+	AttemptHistory []PushAttempt `json:"attempt_history,omitempty"`
+}
+
+type DeadLetterListRequest struct {
+	ClientID  string     `form:"client_id"`
+	ErrorCode string     `form:"error_code"`
+	StartDate *time.Time `form:"start_date"`
+	EndDate   *time.Time `form:"end_date"`
+	Limit     int        `form:"limit"`
+	Offset    int        `form:"offset"`
+}
+
+type DeadLetterListResponse struct {
+	Entries []DeadLetterEntry `json:"entries"`
+	Total   int               `json:"total"`
+	Limit   int               `json:"limit"`
+	Offset  int               `json:"offset"`
+}
+
+// DeadLetterPurgeRequest selects entries to delete. Before is required so a
+// purge never wipes the whole table by accident.
+type DeadLetterPurgeRequest struct {
+	ClientID  string     `form:"client_id"`
+	ErrorCode string     `form:"error_code"`
+	Before    *time.Time `form:"before" binding:"required"`
+}
+
+type DeadLetterPurgeResponse struct {
+	PurgedCount int64 `json:"purged_count"`
+}
@@ -0,0 +1,49 @@
+package model
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	PlatformAndroid = "android"
+	PlatformIOS     = "ios"
+	PlatformWeb     = "web"
+)
+
+type Device struct {
+	ID         uuid.UUID `db:"id" json:"id"`
+	UserID     string    `db:"user_id" json:"user_id"`
+	Token      string    `db:"token" json:"token"`
+	Platform   string    `db:"platform" json:"platform"`
+	AppVersion string    `db:"app_version" json:"app_version,omitempty"`
+	Locale     string    `db:"locale" json:"locale,omitempty"`
+	Timezone   string    `db:"timezone" json:"timezone,omitempty"`
+	Active     bool      `db:"active" json:"active"`
+	LastSeenAt time.Time `db:"last_seen_at" json:"last_seen_at"`
+	CreatedAt  time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt  time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// RegisterDeviceRequest registers a token or refreshes an existing one. When
+// PreviousToken is set, the device that held it is moved to Token.
+type RegisterDeviceRequest struct {
+	UserID        string `json:"user_id" binding:"required,max=255"`
+	Token         string `json:"token" binding:"required,max=255"`
+	PreviousToken string `json:"previous_token,omitempty" binding:"omitempty,max=255"`
+	Platform      string `json:"platform" binding:"required,oneof=android ios web"`
+	AppVersion    string `json:"app_version,omitempty" binding:"omitempty,max=50"`
+	Locale        string `json:"locale,omitempty" binding:"omitempty,max=35"`
+	Timezone      string `json:"timezone,omitempty" binding:"omitempty,max=64"`
+}
+
+type ListDevicesRequest struct {
+	UserID          string `form:"user_id" binding:"required"`
+	IncludeInactive bool   `form:"include_inactive"`
+}
+
+type ListDevicesResponse struct {
+	UserID  string   `json:"user_id"`
+	Devices []Device `json:"devices"`
+}
@@ -0,0 +1,62 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+
+	"github.com/galyym/fcm_push/pkg/fcm"
+)
+
+// PlatformOptions holds the Android, APNs and Webpush settings of a push. It
+// is stored on the task as JSON.
+type PlatformOptions struct {
+	Android *fcm.AndroidOptions `json:"android,omitempty"`
+	APNS    *fcm.APNSOptions    `json:"apns,omitempty"`
+	Webpush *fcm.WebpushOptions `json:"webpush,omitempty"`
+}
+
+// NewPlatformOptions returns nil when no platform settings are given.
+func NewPlatformOptions(android *fcm.AndroidOptions, apns *fcm.APNSOptions, webpush *fcm.WebpushOptions) *PlatformOptions {
+	if android == nil && apns == nil && webpush == nil {
+		return nil
+	}
+	return &PlatformOptions{Android: android, APNS: apns, Webpush: webpush}
+}
+
+func (p *PlatformOptions) Validate() error {
+	if p == nil {
+		return nil
+	}
+	if p.Android != nil {
+		if err := p.Android.Validate(); err != nil {
+			return err
+		}
+	}
+	if p.APNS != nil {
+		if err := p.APNS.Validate(); err != nil {
+			return err
+		}
+	}
+	if p.Webpush != nil {
+		if err := p.Webpush.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p PlatformOptions) Value() (driver.Value, error) {
+	return json.Marshal(p)
+}
+
+func (p *PlatformOptions) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, p)
+	case string:
+		return json.Unmarshal([]byte(v), p)
+	default:
+		return fmt.Errorf("unexpected platform options type %T", value)
+	}
+}
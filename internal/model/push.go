@@ -1,12 +1,50 @@
 package model
 
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/galyym/fcm_push/pkg/fcm"
+)
+
+// PushRequest targets exactly one of a single Token, every active device of
+// UserID, a Topic or a topic Condition. Type "data" sends a data-only
+// background message, which has Data instead of Title and Body. TTL (in
+// seconds from the send time) or ExpiresAt bounds how long the push is worth
+// delivering; once it passes the task is marked expired instead of sent.
 type PushRequest struct {
-	Token    string            `json:"token" binding:"required"`
-	Title    string            `json:"title" binding:"required"`
-	Body     string            `json:"body" binding:"required"`
-	Data     map[string]string `json:"data,omitempty"`
-	Priority string            `json:"priority,omitempty"`
-	ClientID string            `json:"client_id,omitempty"`
+	Token          string              `json:"token" binding:"required_without_all=UserID Topic Condition,excluded_with=UserID Topic Condition"`
+	UserID         string              `json:"user_id,omitempty" binding:"omitempty,max=255,excluded_with=Topic Condition"`
+	Topic          string              `json:"topic,omitempty" binding:"omitempty,max=255,excluded_with=Condition"`
+	Condition      string              `json:"condition,omitempty" binding:"omitempty,max=1000"`
+	Type           string              `json:"type,omitempty" binding:"omitempty,oneof=notification data"`
+	Title          string              `json:"title,omitempty" binding:"required_unless=Type data,excluded_if=Type data"`
+	Body           string              `json:"body,omitempty" binding:"required_unless=Type data,excluded_if=Type data"`
+	Data           map[string]string   `json:"data,omitempty"`
+	Android        *fcm.AndroidOptions `json:"android,omitempty"`
+	APNS           *fcm.APNSOptions    `json:"apns,omitempty"`
+	Webpush        *fcm.WebpushOptions `json:"webpush,omitempty"`
+	Priority       string              `json:"priority,omitempty" binding:"omitempty,oneof=high normal"`
+	TTL            *int                `json:"ttl,omitempty" binding:"omitempty,min=1,max=2419200,excluded_with=ExpiresAt"`
+	ExpiresAt      *time.Time          `json:"expires_at,omitempty"`
+	CollapseKey    string              `json:"collapse_key,omitempty" binding:"omitempty,max=64"`
+	ClientID       string              `json:"client_id,omitempty"`
+	SendAt         *time.Time          `json:"send_at,omitempty"`
+	IdempotencyKey string              `json:"idempotency_key,omitempty" binding:"omitempty,max=255"`
+	RetryPolicy    string              `json:"retry_policy,omitempty" binding:"omitempty,oneof=fixed exponential"`
+}
+
+// RawPushRequest enqueues Message, a complete FCM HTTP v1 message object, to
+// be sent exactly as given. Its target is the message's token, topic or
+// condition. Priority only picks the queue lane; delivery priority is set in
+// the message itself.
+type RawPushRequest struct {
+	Message        json.RawMessage `json:"message" binding:"required"`
+	Priority       string          `json:"priority,omitempty" binding:"omitempty,oneof=high normal"`
+	ClientID       string          `json:"client_id,omitempty"`
+	SendAt         *time.Time      `json:"send_at,omitempty"`
+	IdempotencyKey string          `json:"idempotency_key,omitempty" binding:"omitempty,max=255"`
+	RetryPolicy    string          `json:"retry_policy,omitempty" binding:"omitempty,oneof=fixed exponential"`
 }
 
 type PushResponse struct {
@@ -15,24 +15,82 @@ const (
 	StatusProcessing QueueStatus = "processing"
 	StatusSuccess    QueueStatus = "success"
 	StatusFailed     QueueStatus = "failed"
+	StatusCancelled  QueueStatus = "cancelled"
+	// StatusSkipped marks a task that was not sent because its token is known to be dead.
+	StatusSkipped QueueStatus = "skipped"
+	// StatusExpired marks a task whose expires_at passed before it could be sent.
+	StatusExpired QueueStatus = "expired"
+	// StatusSuperseded marks a pending task replaced by a newer one with the
+	// same client, target and collapse key.
+	StatusSuperseded QueueStatus = "superseded"
+)
+
+const (
+	// ErrorCodeLeaseExpired is set on tasks whose worker lost its claim before recording a result.
+	ErrorCodeLeaseExpired = "LEASE_EXPIRED"
+	// ErrorCodeExpired is set on tasks that were not sent because they expired.
+	ErrorCodeExpired = "EXPIRED"
+	// ErrorCodeSuperseded is set on tasks that were not sent because a newer task replaced them.
+	ErrorCodeSuperseded = "SUPERSEDED"
+)
+
+const (
+	PriorityHigh   = "high"
+	PriorityNormal = "normal"
+)
+
+// Target types of a push: a single registration token, every subscriber of
+// a topic, or the subscribers matching a topic condition.
+const (
+	TargetToken     = "token"
+	TargetTopic     = "topic"
+	TargetCondition = "condition"
+)
+
+// Message types: a visible notification, a data-only background message
+// without title and body, or a complete FCM HTTP v1 message sent as given.
+const (
+	MessageTypeNotification = "notification"
+	MessageTypeData         = "data"
+	MessageTypeRaw          = "raw"
+)
+
+const (
+	RetryPolicyFixed       = "fixed"
+	RetryPolicyExponential = "exponential"
 )
 
 type PushQueueTask struct {
-	ID           uuid.UUID   `db:"id" json:"id"`
-	Token        string      `db:"token" json:"token"`
-	Title        string      `db:"title" json:"title"`
-	Body         string      `db:"body" json:"body"`
-	Data         JSONMap     `db:"data" json:"data,omitempty"`
-	Priority     string      `db:"priority" json:"priority"`
-	ClientID     string      `db:"client_id" json:"client_id,omitempty"`
-	Status       QueueStatus `db:"status" json:"status"`
-	Attempts     int         `db:"attempts" json:"attempts"`
-	MaxAttempts  int         `db:"max_attempts" json:"max_attempts"`
-	ErrorMessage *string     `db:"error_message" json:"error_message,omitempty"`
-	FCMMessageID *string     `db:"fcm_message_id" json:"fcm_message_id,omitempty"`
-	ScheduledAt  time.Time   `db:"scheduled_at" json:"scheduled_at"`
-	CreatedAt    time.Time   `db:"created_at" json:"created_at"`
-	UpdatedAt    time.Time   `db:"updated_at" json:"updated_at"`
+	ID             uuid.UUID        `db:"id" json:"id"`
+	TargetType     string           `db:"target_type" json:"target_type"`
+	Token          string           `db:"token" json:"token,omitempty"`
+	Topic          string           `db:"topic" json:"topic,omitempty"`
+	Condition      string           `db:"condition" json:"condition,omitempty"`
+	GroupID        *uuid.UUID       `db:"group_id" json:"group_id,omitempty"`
+	MessageType    string           `db:"message_type" json:"message_type"`
+	Title          string           `db:"title" json:"title,omitempty"`
+	Body           string           `db:"body" json:"body,omitempty"`
+	Data           JSONMap          `db:"data" json:"data,omitempty"`
+	Platform       *PlatformOptions `db:"platform_options" json:"platform_options,omitempty"`
+	RawMessage     json.RawMessage  `db:"raw_message" json:"raw_message,omitempty"`
+	Priority       string           `db:"priority" json:"priority"`
+	ExpiresAt      *time.Time       `db:"expires_at" json:"expires_at,omitempty"`
+	CollapseKey    string           `db:"collapse_key" json:"collapse_key,omitempty"`
+	ClientID       string           `db:"client_id" json:"client_id,omitempty"`
+	IdempotencyKey *string          `db:"idempotency_key" json:"idempotency_key,omitempty"`
+	Status         QueueStatus      `db:"status" json:"status"`
+	Attempts       int              `db:"attempts" json:"attempts"`
+	MaxAttempts    int              `db:"max_attempts" json:"max_attempts"`
+	RetryPolicy    *string          `db:"retry_policy" json:"retry_policy,omitempty"`
+	ErrorMessage   *string          `db:"error_message" json:"error_message,omitempty"`
+	ErrorCode      *string          `db:"error_code" json:"error_code,omitempty"`
+	FCMMessageID   *string          `db:"fcm_message_id" json:"fcm_message_id,omitempty"`
+	ClaimedBy      *string          `db:"claimed_by" json:"claimed_by,omitempty"`
+	LeaseExpiresAt *time.Time       `db:"lease_expires_at" json:"lease_expires_at,omitempty"`
+	SendAt         *time.Time       `db:"send_at" json:"send_at,omitempty"`
+	ScheduledAt    time.Time        `db:"scheduled_at" json:"scheduled_at"`
+	CreatedAt      time.Time        `db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time        `db:"updated_at" json:"updated_at"`
 }
 
 type JSONMap map[string]string
@@ -58,38 +116,109 @@ func (j *JSONMap) Scan(value interface{}) error {
 	return json.Unmarshal(bytes, j)
 }
 
+// CreateQueueTaskRequest targets exactly one of Token, Topic or Condition.
+// TTL, in seconds from the send time, is an alternative to ExpiresAt. A raw
+// message carries its own target and content in RawMessage.
 type CreateQueueTaskRequest struct {
-	Token       string            `json:"token" binding:"required"`
-	Title       string            `json:"title" binding:"required"`
-	Body        string            `json:"body" binding:"required"`
-	Data        map[string]string `json:"data,omitempty"`
-	Priority    string            `json:"priority,omitempty"`
-	ClientID    string            `json:"client_id,omitempty"`
-	MaxAttempts int               `json:"max_attempts,omitempty"`
+	Token          string            `json:"token,omitempty"`
+	Topic          string            `json:"topic,omitempty"`
+	Condition      string            `json:"condition,omitempty"`
+	MessageType    string            `json:"message_type,omitempty"`
+	Title          string            `json:"title,omitempty"`
+	Body           string            `json:"body,omitempty"`
+	Data           map[string]string `json:"data,omitempty"`
+	Platform       *PlatformOptions  `json:"platform_options,omitempty"`
+	RawMessage     json.RawMessage   `json:"raw_message,omitempty"`
+	Priority       string            `json:"priority,omitempty"`
+	TTL            *int              `json:"ttl,omitempty"`
+	ExpiresAt      *time.Time        `json:"expires_at,omitempty"`
+	CollapseKey    string            `json:"collapse_key,omitempty"`
+	ClientID       string            `json:"client_id,omitempty"`
+	MaxAttempts    int               `json:"max_attempts,omitempty"`
+	SendAt         *time.Time        `json:"send_at,omitempty"`
+	IdempotencyKey string            `json:"idempotency_key,omitempty"`
+	RetryPolicy    string            `json:"retry_policy,omitempty"`
+}
+
+// UpdateQueueTaskRequest changes a task that is still pending. Only the
+// fields that are set are updated.
+type UpdateQueueTaskRequest struct {
+	SendAt   *time.Time        `json:"send_at,omitempty"`
+	Priority *string           `json:"priority,omitempty" binding:"omitempty,oneof=high normal"`
+	Title    *string           `json:"title,omitempty" binding:"omitempty,min=1"`
+	Body     *string           `json:"body,omitempty" binding:"omitempty,min=1"`
+	Data     map[string]string `json:"data,omitempty"`
+}
+
+// TargetType reports which target is set, or "" if none is.
+func (r *CreateQueueTaskRequest) TargetType() string {
+	switch {
+	case r.Token != "":
+		return TargetToken
+	case r.Topic != "":
+		return TargetTopic
+	case r.Condition != "":
+		return TargetCondition
+	default:
+		return ""
+	}
+}
+
+func (r *UpdateQueueTaskRequest) IsEmpty() bool {
+	return r.SendAt == nil && r.Priority == nil && r.Title == nil && r.Body == nil && r.Data == nil
 }
 
 type QueueTaskResponse struct {
 	ID           uuid.UUID   `json:"id"`
 	Status       QueueStatus `json:"status"`
+	TargetType   string      `json:"target_type,omitempty"`
 	Token        string      `json:"token,omitempty"`
+	Topic        string      `json:"topic,omitempty"`
+	Condition    string      `json:"condition,omitempty"`
+	GroupID      *uuid.UUID  `json:"group_id,omitempty"`
+	MessageType  string      `json:"message_type,omitempty"`
 	Title        string      `json:"title,omitempty"`
 	Body         string      `json:"body,omitempty"`
+	ExpiresAt    *time.Time  `json:"expires_at,omitempty"`
+	CollapseKey  string      `json:"collapse_key,omitempty"`
 	ClientID     string      `json:"client_id,omitempty"`
+	Duplicate    bool        `json:"duplicate,omitempty"`
 	Attempts     int         `json:"attempts"`
 	MaxAttempts  int         `json:"max_attempts"`
 	ErrorMessage *string     `json:"error_message,omitempty"`
+	ErrorCode    *string     `json:"error_code,omitempty"`
 	FCMMessageID *string     `json:"fcm_message_id,omitempty"`
+	SendAt       *time.Time  `json:"send_at,omitempty"`
+	ScheduledAt  time.Time   `json:"scheduled_at"`
 	CreatedAt    time.Time   `json:"created_at"`
 	UpdatedAt    time.Time   `json:"updated_at"`
 }
 
 type QueueHistoryRequest struct {
-	ClientID  string      `form:"client_id"`
-	Status    QueueStatus `form:"status"`
-	StartDate *time.Time  `form:"start_date"`
-	EndDate   *time.Time  `form:"end_date"`
-	Limit     int         `form:"limit"`
-	Offset    int         `form:"offset"`
+	ClientID   string      `form:"client_id"`
+	Status     QueueStatus `form:"status"`
+	TargetType string      `form:"target_type" binding:"omitempty,oneof=token topic condition"`
+	StartDate  *time.Time  `form:"start_date"`
+	EndDate    *time.Time  `form:"end_date"`
+	Limit      int         `form:"limit"`
+	Offset     int         `form:"offset"`
+}
+
+// BulkRetryRequest selects failed tasks to put back into the queue. The date
+// range is required so an incident window is always replayed deliberately.
+type BulkRetryRequest struct {
+	ClientID      string     `json:"client_id,omitempty"`
+	StartDate     *time.Time `json:"start_date" binding:"required"`
+	EndDate       *time.Time `json:"end_date" binding:"required"`
+	ErrorContains string     `json:"error_contains,omitempty"`
+	ErrorCode     string     `json:"error_code,omitempty"`
+	DryRun        bool       `json:"dry_run,omitempty"`
+}
+
+type BulkRetryResponse struct {
+	MatchedCount int64 `json:"matched_count"`
+	RetriedCount int64 `json:"retried_count"`
+	DryRun       bool  `json:"dry_run"`
 }
 
 type QueueHistoryResponse struct {
@@ -104,5 +233,19 @@ type QueueStatsResponse struct {
 	ProcessingCount int `json:"processing_count"`
 	SuccessCount    int `json:"success_count"`
 	FailedCount     int `json:"failed_count"`
+	CancelledCount  int `json:"cancelled_count"`
+	SkippedCount    int `json:"skipped_count"`
+	ExpiredCount    int `json:"expired_count"`
+	SupersededCount int `json:"superseded_count"`
 	TotalCount      int `json:"total_count"`
+
+	FCMCircuit *CircuitBreakerStatus `json:"fcm_circuit,omitempty"`
+}
+
+type CircuitBreakerStatus struct {
+	State       string     `json:"state"`
+	Requests    int        `json:"requests"`
+	Failures    int        `json:"failures"`
+	FailureRate float64    `json:"failure_rate"`
+	OpenedAt    *time.Time `json:"opened_at,omitempty"`
 }
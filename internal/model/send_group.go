@@ -0,0 +1,66 @@
+package model
+
+import (
+	"time"
+
+	"github.com/galyym/fcm_push/pkg/fcm"
+	"github.com/google/uuid"
+)
+
+// SendGroup is one multicast request. Every token in it becomes a task
+// carrying the group's ID.
+type SendGroup struct {
+	ID             uuid.UUID `db:"id" json:"id"`
+	ClientID       string    `db:"client_id" json:"client_id,omitempty"`
+	IdempotencyKey *string   `db:"idempotency_key" json:"idempotency_key,omitempty"`
+	Title          string    `db:"title" json:"title,omitempty"`
+	Body           string    `db:"body" json:"body,omitempty"`
+	TokenCount     int       `db:"token_count" json:"token_count"`
+	CreatedAt      time.Time `db:"created_at" json:"created_at"`
+}
+
+// MulticastPushRequest sends one message to many tokens. Duplicate tokens
+// are sent to once. Type, TTL and ExpiresAt work as in PushRequest.
+type MulticastPushRequest struct {
+	Tokens         []string            `json:"tokens" binding:"required,min=1,max=10000,dive,required,max=255"`
+	Type           string              `json:"type,omitempty" binding:"omitempty,oneof=notification data"`
+	Title          string              `json:"title,omitempty" binding:"required_unless=Type data,excluded_if=Type data"`
+	Body           string              `json:"body,omitempty" binding:"required_unless=Type data,excluded_if=Type data"`
+	Data           map[string]string   `json:"data,omitempty"`
+	Android        *fcm.AndroidOptions `json:"android,omitempty"`
+	APNS           *fcm.APNSOptions    `json:"apns,omitempty"`
+	Webpush        *fcm.WebpushOptions `json:"webpush,omitempty"`
+	Priority       string              `json:"priority,omitempty" binding:"omitempty,oneof=high normal"`
+	TTL            *int                `json:"ttl,omitempty" binding:"omitempty,min=1,max=2419200,excluded_with=ExpiresAt"`
+	ExpiresAt      *time.Time          `json:"expires_at,omitempty"`
+	CollapseKey    string              `json:"collapse_key,omitempty" binding:"omitempty,max=64"`
+	ClientID       string              `json:"client_id,omitempty"`
+	SendAt         *time.Time          `json:"send_at,omitempty"`
+	IdempotencyKey string              `json:"idempotency_key,omitempty" binding:"omitempty,max=255"`
+	RetryPolicy    string              `json:"retry_policy,omitempty" binding:"omitempty,oneof=fixed exponential"`
+}
+
+// SendGroupResponse reports a group along with the current status of its
+// tasks. Tasks removed by cleanup are no longer counted. DuplicateTokens and
+// InvalidTokens describe the tokens dropped when the group was created and
+// are only set in that response.
+type SendGroupResponse struct {
+	GroupID         uuid.UUID `json:"group_id"`
+	ClientID        string    `json:"client_id,omitempty"`
+	Title           string    `json:"title,omitempty"`
+	Body            string    `json:"body,omitempty"`
+	TokenCount      int       `json:"token_count"`
+	PendingCount    int       `json:"pending_count"`
+	ProcessingCount int       `json:"processing_count"`
+	SuccessCount    int       `json:"success_count"`
+	FailedCount     int       `json:"failed_count"`
+	CancelledCount  int       `json:"cancelled_count"`
+	SkippedCount    int       `json:"skipped_count"`
+	ExpiredCount    int       `json:"expired_count"`
+	SupersededCount int       `json:"superseded_count"`
+	Completed       bool      `json:"completed"`
+	Duplicate       bool      `json:"duplicate,omitempty"`
+	DuplicateTokens int       `json:"duplicate_tokens,omitempty"`
+	InvalidTokens   []string  `json:"invalid_tokens,omitempty"`
+	CreatedAt       time.Time `json:"created_at"`
+}
@@ -0,0 +1,41 @@
+package model
+
+import "time"
+
+const TokenStatusInvalid = "invalid"
+
+// ErrorCodeTokenInvalid is set on tasks that were skipped or rejected because
+// their token is known to be dead.
+const ErrorCodeTokenInvalid = "TOKEN_INVALID"
+
+// TokenStatus is the recorded health of one FCM token.
+type TokenStatus struct {
+	TokenHash     string    `db:"token_hash" json:"token_hash"`
+	Token         string    `db:"token" json:"token"`
+	Status        string    `db:"status" json:"status"`
+	ErrorCode     *string   `db:"error_code" json:"error_code,omitempty"`
+	ErrorMessage  *string   `db:"error_message" json:"error_message,omitempty"`
+	FailureCount  int       `db:"failure_count" json:"failure_count"`
+	FirstFailedAt time.Time `db:"first_failed_at" json:"first_failed_at"`
+	LastFailedAt  time.Time `db:"last_failed_at" json:"last_failed_at"`
+}
+
+// TokenFailure is an FCM response proving a token is dead.
+type TokenFailure struct {
+	Token        string
+	ErrorCode    string
+	ErrorMessage string
+}
+
+type InvalidTokensRequest struct {
+	Since  *time.Time `form:"since" binding:"required"`
+	Limit  int        `form:"limit"`
+	Offset int        `form:"offset"`
+}
+
+type InvalidTokensResponse struct {
+	Tokens []TokenStatus `json:"tokens"`
+	Total  int           `json:"total"`
+	Limit  int           `json:"limit"`
+	Offset int           `json:"offset"`
+}
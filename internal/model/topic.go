@@ -0,0 +1,29 @@
+package model
+
+// TopicTokensRequest lists the tokens to (un)subscribe; FCM accepts at most
+// 1000 per call.
+type TopicTokensRequest struct {
+	Tokens []string `json:"tokens" binding:"required,min=1,max=1000,dive,required,max=255"`
+}
+
+type TopicTokenFailure struct {
+	Index  int    `json:"index"`
+	Token  string `json:"token"`
+	Reason string `json:"reason"`
+}
+
+type TopicManagementResponse struct {
+	Topic        string              `json:"topic"`
+	SuccessCount int                 `json:"success_count"`
+	FailureCount int                 `json:"failure_count"`
+	Failures     []TopicTokenFailure `json:"failures,omitempty"`
+}
+
+type TokenTopicsRequest struct {
+	Token string `form:"token" binding:"required"`
+}
+
+type TokenTopicsResponse struct {
+	Token  string   `json:"token"`
+	Topics []string `json:"topics"`
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/galyym/fcm_push/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+// RecordAttempts appends send attempts to the delivery log.
+func (r *QueueRepository) RecordAttempts(ctx context.Context, attempts []model.PushAttempt) error {
+	if len(attempts) == 0 {
+		return nil
+	}
+
+	columns := []string{
+		"task_id", "attempt", "worker_id", "started_at", "finished_at", "latency_ms",
+		"outcome", "error_code", "error_message", "fcm_message_id",
+	}
+
+	_, err := r.db.Pool.CopyFrom(ctx, pgx.Identifier{"push_attempts"}, columns,
+		pgx.CopyFromSlice(len(attempts), func(i int) ([]interface{}, error) {
+			a := attempts[i]
+			return []interface{}{
+				a.TaskID, a.Attempt, a.WorkerID, a.StartedAt, a.FinishedAt, a.LatencyMs,
+				a.Outcome, a.ErrorCode, a.ErrorMessage, a.FCMMessageID,
+			}, nil
+		}),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record attempts: %w", err)
+	}
+
+	return nil
+}
+
+func (r *QueueRepository) GetAttempts(ctx context.Context, taskID uuid.UUID) ([]model.PushAttempt, error) {
+	query := `
+		SELECT task_id, attempt, worker_id, started_at, finished_at, latency_ms,
+		       outcome, error_code, error_message, fcm_message_id
+		FROM push_attempts
+		WHERE task_id = $1
+		ORDER BY started_at ASC, id ASC
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attempts: %w", err)
+	}
+	defer rows.Close()
+
+	attempts := []model.PushAttempt{}
+	for rows.Next() {
+		var a model.PushAttempt
+		err := rows.Scan(
+			&a.TaskID, &a.Attempt, &a.WorkerID, &a.StartedAt, &a.FinishedAt, &a.LatencyMs,
+			&a.Outcome, &a.ErrorCode, &a.ErrorMessage, &a.FCMMessageID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan attempt: %w", err)
+		}
+		attempts = append(attempts, a)
+	}
+
+	return attempts, rows.Err()
+}
+
+// CleanupAttempts deletes attempts older than olderThan, except those of
+// dead-lettered tasks, which live as long as their dead-letter entry.
+func (r *QueueRepository) CleanupAttempts(ctx context.Context, olderThan time.Duration) (int64, error) {
+	query := `
+		DELETE FROM push_attempts a
+		WHERE a.finished_at < $1
+		  AND NOT EXISTS (SELECT 1 FROM push_dead_letter d WHERE d.id = a.task_id)
+	`
+
+	cutoffTime := time.Now().Add(-olderThan)
+	result, err := r.db.Pool.Exec(ctx, query, cutoffTime)
+	if err != nil {
+		return 0, fmt.Errorf("failed to cleanup attempts: %w", err)
+	}
+
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,262 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/galyym/fcm_push/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrDeadLetterNotFound = errors.New("dead letter entry not found")
+
+const deadLetterColumns = `
+	id, target_type, COALESCE(token, '') AS token, COALESCE(topic, '') AS topic, COALESCE(condition, '') AS condition,
+	message_type, COALESCE(title, '') AS title, COALESCE(body, '') AS body, data, platform_options, raw_message,
+	priority, expires_at, COALESCE(collapse_key, '') AS collapse_key, client_id, attempts, max_attempts,
+	retry_policy, error_message, error_code, send_at, task_created_at, dead_lettered_at
+`
+
+// deadLetterInsert copies the failed tasks selected by the %s clause (a FROM
+// source, optionally with a WHERE) into push_dead_letter.
+const deadLetterInsert = `
+	INSERT INTO push_dead_letter (
+		id, target_type, token, topic, condition, message_type, title, body, data, platform_options, raw_message, priority,
+		expires_at, collapse_key, client_id, attempts, max_attempts, retry_policy, error_message, error_code, send_at, task_created_at
+	)
+	SELECT id, target_type, token, topic, condition, message_type, title, body, data, platform_options, raw_message, priority,
+	       expires_at, collapse_key, client_id, attempts, max_attempts, retry_policy, error_message, error_code, send_at, created_at
+	FROM %s
+	ON CONFLICT (id) DO UPDATE
+	SET attempts = EXCLUDED.attempts,
+	    error_message = EXCLUDED.error_message,
+	    error_code = EXCLUDED.error_code,
+	    dead_lettered_at = NOW()
+`
+
+func scanDeadLetter(row rowScanner) (*model.DeadLetterEntry, error) {
+	entry := &model.DeadLetterEntry{}
+	err := row.Scan(
+		&entry.ID, &entry.TargetType, &entry.Token, &entry.Topic, &entry.Condition, &entry.MessageType, &entry.Title, &entry.Body, &entry.Data, &entry.Platform, &entry.RawMessage, &entry.Priority,
+		&entry.ExpiresAt, &entry.CollapseKey, &entry.ClientID,
+		&entry.Attempts, &entry.MaxAttempts, &entry.RetryPolicy, &entry.ErrorMessage, &entry.ErrorCode,
+		&entry.SendAt, &entry.TaskCreatedAt, &entry.DeadLetteredAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// deadLetterFilter builds the WHERE clause shared by listing and purging.
+func deadLetterFilter(clientID, errorCode string, start, end *time.Time) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	argPos := 1
+
+	if clientID != "" {
+		conditions = append(conditions, fmt.Sprintf("client_id = $%d", argPos))
+		args = append(args, clientID)
+		argPos++
+	}
+
+	if errorCode != "" {
+		conditions = append(conditions, fmt.Sprintf("error_code = $%d", argPos))
+		args = append(args, errorCode)
+		argPos++
+	}
+
+	if start != nil {
+		conditions = append(conditions, fmt.Sprintf("dead_lettered_at >= $%d", argPos))
+		args = append(args, *start)
+		argPos++
+	}
+
+	if end != nil {
+		conditions = append(conditions, fmt.Sprintf("dead_lettered_at <= $%d", argPos))
+		args = append(args, *end)
+		argPos++
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return "WHERE " + strings.Join(conditions, " AND "), args
+}
+
+func (r *QueueRepository) ListDeadLetters(ctx context.Context, req *model.DeadLetterListRequest) (*model.DeadLetterListResponse, error) {
+	whereClause, args := deadLetterFilter(req.ClientID, req.ErrorCode, req.StartDate, req.EndDate)
+
+	var total int
+	err := r.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM push_dead_letter "+whereClause, args...).Scan(&total)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get total count: %w", err)
+	}
+
+	if req.Limit <= 0 {
+		req.Limit = 50
+	}
+	if req.Offset < 0 {
+		req.Offset = 0
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM push_dead_letter
+		%s
+		ORDER BY dead_lettered_at DESC
+		LIMIT $%d OFFSET $%d
+	`, deadLetterColumns, whereClause, len(args)+1, len(args)+2)
+
+	args = append(args, req.Limit, req.Offset)
+
+	rows, err := r.db.Pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead letters: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []model.DeadLetterEntry{}
+	for rows.Next() {
+		entry, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan dead letter: %w", err)
+		}
+		entries = append(entries, *entry)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list dead letters: %w", err)
+	}
+
+	return &model.DeadLetterListResponse{
+		Entries: entries,
+		Total:   total,
+		Limit:   req.Limit,
+		Offset:  req.Offset,
+	}, nil
+}
+
+func (r *QueueRepository) GetDeadLetter(ctx context.Context, id uuid.UUID) (*model.DeadLetterEntry, error) {
+	query := `SELECT ` + deadLetterColumns + ` FROM push_dead_letter WHERE id = $1`
+
+	entry, err := scanDeadLetter(r.db.Pool.QueryRow(ctx, query, id))
+	if err == pgx.ErrNoRows {
+		return nil, ErrDeadLetterNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dead letter: %w", err)
+	}
+
+	return entry, nil
+}
+
+// RequeueDeadLetter removes an entry from the dead-letter table and puts its
+// task back to pending with attempts reset. If the failed task has already
+// been cleaned up from push_queue, it is recreated from the entry under the
+// same ID.
+func (r *QueueRepository) RequeueDeadLetter(ctx context.Context, id uuid.UUID) (*model.PushQueueTask, error) {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	entry, err := scanDeadLetter(tx.QueryRow(ctx, `DELETE FROM push_dead_letter WHERE id = $1 RETURNING `+deadLetterColumns, id))
+	if err == pgx.ErrNoRows {
+		return nil, ErrDeadLetterNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove dead letter: %w", err)
+	}
+
+	query := `
+		UPDATE push_queue
+		SET status = $1, attempts = 0, scheduled_at = NOW(), updated_at = NOW()
+		WHERE id = $2 AND status = $3
+		RETURNING ` + taskColumns
+
+	task, err := scanTask(tx.QueryRow(ctx, query, model.StatusPending, id, model.StatusFailed))
+	if err == pgx.ErrNoRows {
+		now := time.Now()
+		task = &model.PushQueueTask{
+			ID:           entry.ID,
+			TargetType:   entry.TargetType,
+			Token:        entry.Token,
+			Topic:        entry.Topic,
+			Condition:    entry.Condition,
+			MessageType:  entry.MessageType,
+			Title:        entry.Title,
+			Body:         entry.Body,
+			Data:         entry.Data,
+			Platform:     entry.Platform,
+			RawMessage:   entry.RawMessage,
+			Priority:     entry.Priority,
+			ExpiresAt:    entry.ExpiresAt,
+			CollapseKey:  entry.CollapseKey,
+			ClientID:     entry.ClientID,
+			Status:       model.StatusPending,
+			MaxAttempts:  entry.MaxAttempts,
+			RetryPolicy:  entry.RetryPolicy,
+			ErrorMessage: entry.ErrorMessage,
+			ErrorCode:    entry.ErrorCode,
+			SendAt:       entry.SendAt,
+			ScheduledAt:  now,
+			CreatedAt:    entry.TaskCreatedAt,
+			UpdatedAt:    now,
+		}
+		if _, err := insertTask(ctx, tx, task); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to requeue dead letter: %w", err)
+	}
+
+	if err := notifyNewTask(ctx, tx, task.ID.String()); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit requeue: %w", err)
+	}
+
+	return task, nil
+}
+
+func (r *QueueRepository) DeleteDeadLetter(ctx context.Context, id uuid.UUID) error {
+	result, err := r.db.Pool.Exec(ctx, `DELETE FROM push_dead_letter WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete dead letter: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrDeadLetterNotFound
+	}
+
+	return nil
+}
+
+// PurgeDeadLetters deletes every entry matching req that was dead-lettered
+// before req.Before.
+func (r *QueueRepository) PurgeDeadLetters(ctx context.Context, req *model.DeadLetterPurgeRequest) (int64, error) {
+	whereClause, args := deadLetterFilter(req.ClientID, req.ErrorCode, nil, req.Before)
+
+	result, err := r.db.Pool.Exec(ctx, "DELETE FROM push_dead_letter "+whereClause, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to purge dead letters: %w", err)
+	}
+
+	return result.RowsAffected(), nil
+}
+
+func (r *QueueRepository) CleanupDeadLetters(ctx context.Context, olderThan time.Duration) (int64, error) {
+	cutoffTime := time.Now().Add(-olderThan)
+	result, err := r.db.Pool.Exec(ctx, `DELETE FROM push_dead_letter WHERE dead_lettered_at < $1`, cutoffTime)
+	if err != nil {
+		return 0, fmt.Errorf("failed to cleanup dead letters: %w", err)
+	}
+
+	return result.RowsAffected(), nil
+}
@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/galyym/fcm_push/internal/database"
+	"github.com/galyym/fcm_push/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrDeviceNotFound = errors.New("device not found")
+
+const deviceColumns = `
+	id, user_id, token, platform, app_version, locale, timezone,
+	active, last_seen_at, created_at, updated_at
+`
+
+func scanDevice(row rowScanner) (*model.Device, error) {
+	device := &model.Device{}
+	err := row.Scan(
+		&device.ID, &device.UserID, &device.Token, &device.Platform, &device.AppVersion, &device.Locale, &device.Timezone,
+		&device.Active, &device.LastSeenAt, &device.CreatedAt, &device.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+type DeviceRepository struct {
+	db *database.DB
+}
+
+func NewDeviceRepository(db *database.DB) *DeviceRepository {
+	return &DeviceRepository{db: db}
+}
+
+// Register stores req's token for its user, or refreshes the device that
+// already holds it. A token belongs to one user at a time, so registering it
+// for another user moves it. With PreviousToken set, the device holding the
+// previous token takes over the new one.
+func (r *DeviceRepository) Register(ctx context.Context, req *model.RegisterDeviceRequest) (*model.Device, error) {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	// The app just obtained this token from FCM, so an earlier failure no longer applies.
+	if _, err := tx.Exec(ctx, `DELETE FROM token_status WHERE token_hash = $1`, HashToken(req.Token)); err != nil {
+		return nil, fmt.Errorf("failed to clear token status: %w", err)
+	}
+
+	if req.PreviousToken != "" && req.PreviousToken != req.Token {
+		var previousID uuid.UUID
+		err := tx.QueryRow(ctx, `SELECT id FROM devices WHERE token = $1 FOR UPDATE`, req.PreviousToken).Scan(&previousID)
+		if err != nil && err != pgx.ErrNoRows {
+			return nil, fmt.Errorf("failed to find previous token: %w", err)
+		}
+
+		if err == nil {
+			if _, err := tx.Exec(ctx, `DELETE FROM devices WHERE token = $1`, req.Token); err != nil {
+				return nil, fmt.Errorf("failed to release new token: %w", err)
+			}
+
+			query := `
+				UPDATE devices
+				SET token = $1, user_id = $2, platform = $3, app_version = $4, locale = $5, timezone = $6,
+				    active = TRUE, last_seen_at = NOW(), updated_at = NOW()
+				WHERE id = $7
+				RETURNING ` + deviceColumns
+
+			device, err := scanDevice(tx.QueryRow(ctx, query,
+				req.Token, req.UserID, req.Platform, req.AppVersion, req.Locale, req.Timezone, previousID,
+			))
+			if err != nil {
+				return nil, fmt.Errorf("failed to refresh device token: %w", err)
+			}
+
+			if err := tx.Commit(ctx); err != nil {
+				return nil, fmt.Errorf("failed to commit device: %w", err)
+			}
+			return device, nil
+		}
+	}
+
+	query := `
+		INSERT INTO devices (id, user_id, token, platform, app_version, locale, timezone)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (token) DO UPDATE
+		SET user_id = EXCLUDED.user_id,
+		    platform = EXCLUDED.platform,
+		    app_version = EXCLUDED.app_version,
+		    locale = EXCLUDED.locale,
+		    timezone = EXCLUDED.timezone,
+		    active = TRUE,
+		    last_seen_at = NOW(),
+		    updated_at = NOW()
+		RETURNING ` + deviceColumns
+
+	device, err := scanDevice(tx.QueryRow(ctx, query,
+		uuid.New(), req.UserID, req.Token, req.Platform, req.AppVersion, req.Locale, req.Timezone,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("failed to register device: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit device: %w", err)
+	}
+
+	return device, nil
+}
+
+func (r *DeviceRepository) ListByUser(ctx context.Context, userID string, includeInactive bool) ([]model.Device, error) {
+	query := `
+		SELECT ` + deviceColumns + `
+		FROM devices
+		WHERE user_id = $1 AND (active OR $2)
+		ORDER BY last_seen_at DESC
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query, userID, includeInactive)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list devices: %w", err)
+	}
+	defer rows.Close()
+
+	devices := []model.Device{}
+	for rows.Next() {
+		device, err := scanDevice(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan device: %w", err)
+		}
+		devices = append(devices, *device)
+	}
+
+	return devices, rows.Err()
+}
+
+func (r *DeviceRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	result, err := r.db.Pool.Exec(ctx, `DELETE FROM devices WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete device: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrDeviceNotFound
+	}
+
+	return nil
+}
+
+func (r *DeviceRepository) DeleteByToken(ctx context.Context, token string) error {
+	result, err := r.db.Pool.Exec(ctx, `DELETE FROM devices WHERE token = $1`, token)
+	if err != nil {
+		return fmt.Errorf("failed to delete device: %w", err)
+	}
+	if result.RowsAffected() == 0 {
+		return ErrDeviceNotFound
+	}
+
+	return nil
+}
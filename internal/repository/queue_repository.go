@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -12,6 +13,48 @@ import (
 	"github.com/jackc/pgx/v5"
 )
 
+var (
+	ErrTaskNotFound   = errors.New("task not found")
+	ErrTaskNotPending = errors.New("task is not pending")
+	ErrTaskNotFailed  = errors.New("task is not failed")
+	ErrDuplicateTask  = errors.New("idempotency key already used")
+	// ErrLeaseLost is returned when a worker records the result of a task it
+	// no longer holds, e.g. because its lease expired and the task was reclaimed.
+	ErrLeaseLost = errors.New("task is no longer claimed by this worker")
+)
+
+// NewTaskChannel is the Postgres NOTIFY channel signalled whenever a task is
+// enqueued, so idle workers can wake up without waiting for the next poll.
+const NewTaskChannel = "push_queue_new_task"
+
+// taskColumns reads the unused target columns of a task as empty strings.
+const taskColumns = `
+	id, target_type, COALESCE(token, '') AS token, COALESCE(topic, '') AS topic, COALESCE(condition, '') AS condition,
+	group_id, message_type, COALESCE(title, '') AS title, COALESCE(body, '') AS body, data, platform_options, raw_message,
+	priority, expires_at, COALESCE(collapse_key, '') AS collapse_key, client_id, idempotency_key,
+	status, attempts, max_attempts, retry_policy, error_message, error_code, fcm_message_id,
+	claimed_by, lease_expires_at, send_at, scheduled_at, created_at, updated_at
+`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTask(row rowScanner) (*model.PushQueueTask, error) {
+	task := &model.PushQueueTask{}
+	err := row.Scan(
+		&task.ID, &task.TargetType, &task.Token, &task.Topic, &task.Condition,
+		&task.GroupID, &task.MessageType, &task.Title, &task.Body, &task.Data, &task.Platform, &task.RawMessage,
+		&task.Priority, &task.ExpiresAt, &task.CollapseKey, &task.ClientID, &task.IdempotencyKey,
+		&task.Status, &task.Attempts, &task.MaxAttempts, &task.RetryPolicy, &task.ErrorMessage, &task.ErrorCode, &task.FCMMessageID,
+		&task.ClaimedBy, &task.LeaseExpiresAt, &task.SendAt, &task.ScheduledAt, &task.CreatedAt, &task.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return task, nil
+}
+
 type QueueRepository struct {
 	db *database.DB
 }
@@ -20,71 +63,254 @@ func NewQueueRepository(db *database.DB) *QueueRepository {
 	return &QueueRepository{db: db}
 }
 
-func (r *QueueRepository) CreateTask(ctx context.Context, req *model.CreateQueueTaskRequest) (*model.PushQueueTask, error) {
+func newTask(req *model.CreateQueueTaskRequest) *model.PushQueueTask {
+	now := time.Now()
 	task := &model.PushQueueTask{
 		ID:          uuid.New(),
+		TargetType:  req.TargetType(),
 		Token:       req.Token,
+		Topic:       req.Topic,
+		Condition:   req.Condition,
+		MessageType: req.MessageType,
 		Title:       req.Title,
 		Body:        req.Body,
 		Data:        req.Data,
+		Platform:    req.Platform,
+		RawMessage:  req.RawMessage,
 		Priority:    req.Priority,
+		ExpiresAt:   req.ExpiresAt,
+		CollapseKey: req.CollapseKey,
 		ClientID:    req.ClientID,
 		Status:      model.StatusPending,
 		Attempts:    0,
 		MaxAttempts: req.MaxAttempts,
-		ScheduledAt: time.Now(),
-		CreatedAt:   time.Now(),
-		UpdatedAt:   time.Now(),
+		SendAt:      req.SendAt,
+		ScheduledAt: now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	if req.SendAt != nil {
+		task.ScheduledAt = *req.SendAt
+	}
+	if req.IdempotencyKey != "" {
+		task.IdempotencyKey = &req.IdempotencyKey
+	}
+	if req.RetryPolicy != "" {
+		task.RetryPolicy = &req.RetryPolicy
 	}
 
+	if task.MessageType == "" {
+		task.MessageType = model.MessageTypeNotification
+	}
 	if task.Priority == "" {
-		task.Priority = "normal"
+		task.Priority = model.PriorityNormal
 	}
 	if task.MaxAttempts == 0 {
 		task.MaxAttempts = 3
 	}
 
+	return task
+}
+
+// insertTask inserts task within tx. It reports false without error when the
+// task's idempotency key is already taken for its client.
+func insertTask(ctx context.Context, tx pgx.Tx, task *model.PushQueueTask) (bool, error) {
 	query := `
 		INSERT INTO push_queue (
-			id, token, title, body, data, priority, client_id,
-			status, attempts, max_attempts, scheduled_at, created_at, updated_at
+			id, target_type, token, topic, condition, message_type, title, body, data, platform_options, raw_message,
+			priority, expires_at, collapse_key, client_id, idempotency_key,
+			status, attempts, max_attempts, retry_policy, send_at, scheduled_at, created_at, updated_at
 		) VALUES (
-			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13
+			$1, $2, NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''), $6, NULLIF($7, ''), NULLIF($8, ''), $9, $10, $11,
+			$12, $13, NULLIF($14, ''), $15, $16,
+			$17, $18, $19, $20, $21, $22, $23, $24
 		)
+		ON CONFLICT (client_id, idempotency_key) WHERE idempotency_key IS NOT NULL DO NOTHING
 		RETURNING id, created_at, updated_at
 	`
 
-	err := r.db.Pool.QueryRow(
+	err := tx.QueryRow(
 		ctx, query,
-		task.ID, task.Token, task.Title, task.Body, task.Data, task.Priority, task.ClientID,
-		task.Status, task.Attempts, task.MaxAttempts, task.ScheduledAt, task.CreatedAt, task.UpdatedAt,
+		task.ID, task.TargetType, task.Token, task.Topic, task.Condition, task.MessageType, task.Title, task.Body, task.Data, task.Platform, task.RawMessage,
+		task.Priority, task.ExpiresAt, task.CollapseKey, task.ClientID, task.IdempotencyKey,
+		task.Status, task.Attempts, task.MaxAttempts, task.RetryPolicy, task.SendAt, task.ScheduledAt, task.CreatedAt, task.UpdatedAt,
 	).Scan(&task.ID, &task.CreatedAt, &task.UpdatedAt)
 
+	if err == pgx.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to create task: %w", err)
+	}
+
+	return true, nil
+}
+
+// CreateTask inserts a pending task. A task with a collapse key supersedes
+// the pending tasks it replaces.
+func (r *QueueRepository) CreateTask(ctx context.Context, req *model.CreateQueueTaskRequest) (*model.PushQueueTask, error) {
+	task := newTask(req)
+
+	tx, err := r.db.Pool.Begin(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create task: %w", err)
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	inserted, err := insertTask(ctx, tx, task)
+	if err != nil {
+		return nil, err
+	}
+	if !inserted {
+		return nil, fmt.Errorf("failed to create task: %w", ErrDuplicateTask)
+	}
+
+	if err := supersedePending(ctx, tx, task); err != nil {
+		return nil, err
+	}
+
+	if err := notifyNewTask(ctx, tx, task.ID.String()); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit task: %w", err)
 	}
 
 	return task, nil
 }
 
-func (r *QueueRepository) GetTaskByID(ctx context.Context, id uuid.UUID) (*model.PushQueueTask, error) {
+// CreateTaskIdempotent creates a task unless the client already enqueued one
+// with the same idempotency key within retention. In that case the original
+// task is returned and created is false. Keys older than retention are
+// released so they can be reused. A created task supersedes pending tasks as
+// in CreateTask.
+func (r *QueueRepository) CreateTaskIdempotent(ctx context.Context, req *model.CreateQueueTaskRequest, retention time.Duration) (task *model.PushQueueTask, created bool, err error) {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	releaseQuery := `
+		UPDATE push_queue
+		SET idempotency_key = NULL
+		WHERE client_id = $1 AND idempotency_key = $2 AND created_at < $3
+	`
+	if _, err := tx.Exec(ctx, releaseQuery, req.ClientID, req.IdempotencyKey, time.Now().Add(-retention)); err != nil {
+		return nil, false, fmt.Errorf("failed to release expired idempotency key: %w", err)
+	}
+
+	task = newTask(req)
+	inserted, err := insertTask(ctx, tx, task)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if !inserted {
+		query := `SELECT ` + taskColumns + ` FROM push_queue WHERE client_id = $1 AND idempotency_key = $2`
+		task, err = scanTask(tx.QueryRow(ctx, query, req.ClientID, req.IdempotencyKey))
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to get task by idempotency key: %w", err)
+		}
+		return task, false, tx.Commit(ctx)
+	}
+
+	if err := supersedePending(ctx, tx, task); err != nil {
+		return nil, false, err
+	}
+
+	if err := notifyNewTask(ctx, tx, task.ID.String()); err != nil {
+		return nil, false, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, false, fmt.Errorf("failed to commit task: %w", err)
+	}
+
+	return task, true, nil
+}
+
+// supersedePending marks the pending tasks that task replaces as superseded:
+// those of the same client with the same target and collapse key. Tasks
+// already claimed by a worker are left alone.
+func supersedePending(ctx context.Context, tx pgx.Tx, task *model.PushQueueTask) error {
+	if task.CollapseKey == "" {
+		return nil
+	}
+
 	query := `
-		SELECT id, token, title, body, data, priority, client_id,
-		       status, attempts, max_attempts, error_message, fcm_message_id,
-		       scheduled_at, created_at, updated_at
-		FROM push_queue
-		WHERE id = $1
+		UPDATE push_queue
+		SET status = $1,
+		    error_message = $2,
+		    error_code = $3,
+		    updated_at = NOW()
+		WHERE status = $4
+		  AND client_id = $5
+		  AND collapse_key = $6
+		  AND target_type = $7
+		  AND token IS NOT DISTINCT FROM NULLIF($8, '')
+		  AND topic IS NOT DISTINCT FROM NULLIF($9, '')
+		  AND condition IS NOT DISTINCT FROM NULLIF($10, '')
+		  AND id <> $11
 	`
 
-	task := &model.PushQueueTask{}
-	err := r.db.Pool.QueryRow(ctx, query, id).Scan(
-		&task.ID, &task.Token, &task.Title, &task.Body, &task.Data, &task.Priority, &task.ClientID,
-		&task.Status, &task.Attempts, &task.MaxAttempts, &task.ErrorMessage, &task.FCMMessageID,
-		&task.ScheduledAt, &task.CreatedAt, &task.UpdatedAt,
+	_, err := tx.Exec(ctx, query,
+		model.StatusSuperseded, "superseded by task "+task.ID.String(), model.ErrorCodeSuperseded, model.StatusPending,
+		task.ClientID, task.CollapseKey, task.TargetType, task.Token, task.Topic, task.Condition, task.ID,
 	)
+	if err != nil {
+		return fmt.Errorf("failed to supersede pending tasks: %w", err)
+	}
+
+	return nil
+}
+
+// notifyNewTask signals NewTaskChannel. NOTIFY is delivered on commit, so
+// listeners never see a task that was rolled back.
+func notifyNewTask(ctx context.Context, tx pgx.Tx, payload string) error {
+	if _, err := tx.Exec(ctx, "SELECT pg_notify($1, $2)", NewTaskChannel, payload); err != nil {
+		return fmt.Errorf("failed to notify workers: %w", err)
+	}
+	return nil
+}
+
+// ListenForNewTasks holds a dedicated connection listening on NewTaskChannel
+// and calls onNotify for every notification. It blocks until ctx is cancelled
+// or the connection fails; callers are expected to reconnect on error.
+func (r *QueueRepository) ListenForNewTasks(ctx context.Context, onNotify func()) error {
+	poolConn, err := r.db.Pool.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire listener connection: %w", err)
+	}
+
+	// Take the connection out of the pool so the LISTEN state never leaks to other queries.
+	conn := poolConn.Hijack()
+	defer conn.Close(context.Background())
+
+	if _, err := conn.Exec(ctx, "LISTEN "+pgx.Identifier{NewTaskChannel}.Sanitize()); err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", NewTaskChannel, err)
+	}
+
+	for {
+		if _, err := conn.WaitForNotification(ctx); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return fmt.Errorf("failed to wait for notification: %w", err)
+		}
+		onNotify()
+	}
+}
+
+func (r *QueueRepository) GetTaskByID(ctx context.Context, id uuid.UUID) (*model.PushQueueTask, error) {
+	query := `SELECT ` + taskColumns + ` FROM push_queue WHERE id = $1`
+
+	task, err := scanTask(r.db.Pool.QueryRow(ctx, query, id))
 
 	if err == pgx.ErrNoRows {
-		return nil, fmt.Errorf("task not found")
+		return nil, ErrTaskNotFound
 	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to get task: %w", err)
@@ -93,25 +319,246 @@ func (r *QueueRepository) GetTaskByID(ctx context.Context, id uuid.UUID) (*model
 	return task, nil
 }
 
-func (r *QueueRepository) GetPendingTasks(ctx context.Context, limit int) ([]*model.PushQueueTask, error) {
+// CancelTask marks a pending task as cancelled. The status check in the
+// UPDATE makes it safe against workers claiming the same row: whichever
+// statement locks the row first wins, and the other sees the new status.
+func (r *QueueRepository) CancelTask(ctx context.Context, id uuid.UUID) (*model.PushQueueTask, error) {
 	query := `
 		UPDATE push_queue
 		SET status = $1, updated_at = NOW()
+		WHERE id = $2 AND status = $3
+		RETURNING ` + taskColumns
+
+	task, err := scanTask(r.db.Pool.QueryRow(ctx, query, model.StatusCancelled, id, model.StatusPending))
+	if err == pgx.ErrNoRows {
+		return nil, r.statusError(ctx, id, ErrTaskNotPending)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel task: %w", err)
+	}
+
+	return task, nil
+}
+
+// UpdatePendingTask applies the set fields of req to a task that is still
+// pending. Like CancelTask it never touches a task a worker has claimed.
+func (r *QueueRepository) UpdatePendingTask(ctx context.Context, id uuid.UUID, req *model.UpdateQueueTaskRequest) (*model.PushQueueTask, error) {
+	var sets []string
+	var args []interface{}
+	argPos := 1
+
+	if req.SendAt != nil {
+		sets = append(sets, fmt.Sprintf("send_at = $%d, scheduled_at = $%d", argPos, argPos))
+		args = append(args, *req.SendAt)
+		argPos++
+	}
+
+	if req.Priority != nil {
+		sets = append(sets, fmt.Sprintf("priority = $%d", argPos))
+		args = append(args, *req.Priority)
+		argPos++
+	}
+
+	if req.Title != nil {
+		sets = append(sets, fmt.Sprintf("title = $%d", argPos))
+		args = append(args, *req.Title)
+		argPos++
+	}
+
+	if req.Body != nil {
+		sets = append(sets, fmt.Sprintf("body = $%d", argPos))
+		args = append(args, *req.Body)
+		argPos++
+	}
+
+	if req.Data != nil {
+		sets = append(sets, fmt.Sprintf("data = $%d", argPos))
+		args = append(args, model.JSONMap(req.Data))
+		argPos++
+	}
+
+	sets = append(sets, "updated_at = NOW()")
+
+	query := fmt.Sprintf(`
+		UPDATE push_queue
+		SET %s
+		WHERE id = $%d AND status = $%d
+		RETURNING %s
+	`, strings.Join(sets, ", "), argPos, argPos+1, taskColumns)
+
+	args = append(args, id, model.StatusPending)
+
+	task, err := scanTask(r.db.Pool.QueryRow(ctx, query, args...))
+	if err == pgx.ErrNoRows {
+		return nil, r.statusError(ctx, id, ErrTaskNotPending)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to update task: %w", err)
+	}
+
+	return task, nil
+}
+
+// RetryTask puts a failed task back to pending with its attempts reset and
+// drops its dead-letter entry.
+func (r *QueueRepository) RetryTask(ctx context.Context, id uuid.UUID) (*model.PushQueueTask, error) {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		UPDATE push_queue
+		SET status = $1, attempts = 0, scheduled_at = NOW(), updated_at = NOW()
+		WHERE id = $2 AND status = $3
+		RETURNING ` + taskColumns
+
+	task, err := scanTask(tx.QueryRow(ctx, query, model.StatusPending, id, model.StatusFailed))
+	if err == pgx.ErrNoRows {
+		return nil, r.statusError(ctx, id, ErrTaskNotFailed)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retry task: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM push_dead_letter WHERE id = $1`, id); err != nil {
+		return nil, fmt.Errorf("failed to remove dead letter: %w", err)
+	}
+
+	if err := notifyNewTask(ctx, tx, task.ID.String()); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, fmt.Errorf("failed to commit retry: %w", err)
+	}
+
+	return task, nil
+}
+
+// RetryFailedTasks puts every failed task matching req back to pending with
+// its attempts reset. With DryRun set it only counts the matching tasks.
+func (r *QueueRepository) RetryFailedTasks(ctx context.Context, req *model.BulkRetryRequest) (int64, error) {
+	conditions := []string{"status = $1"}
+	args := []interface{}{model.StatusFailed}
+	argPos := 2
+
+	if req.ClientID != "" {
+		conditions = append(conditions, fmt.Sprintf("client_id = $%d", argPos))
+		args = append(args, req.ClientID)
+		argPos++
+	}
+
+	if req.StartDate != nil {
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", argPos))
+		args = append(args, *req.StartDate)
+		argPos++
+	}
+
+	if req.EndDate != nil {
+		conditions = append(conditions, fmt.Sprintf("created_at <= $%d", argPos))
+		args = append(args, *req.EndDate)
+		argPos++
+	}
+
+	if req.ErrorContains != "" {
+		conditions = append(conditions, fmt.Sprintf("strpos(error_message, $%d) > 0", argPos))
+		args = append(args, req.ErrorContains)
+		argPos++
+	}
+
+	if req.ErrorCode != "" {
+		conditions = append(conditions, fmt.Sprintf("error_code = $%d", argPos))
+		args = append(args, req.ErrorCode)
+		argPos++
+	}
+
+	whereClause := "WHERE " + strings.Join(conditions, " AND ")
+
+	if req.DryRun {
+		var count int64
+		err := r.db.Pool.QueryRow(ctx, "SELECT COUNT(*) FROM push_queue "+whereClause, args...).Scan(&count)
+		if err != nil {
+			return 0, fmt.Errorf("failed to count failed tasks: %w", err)
+		}
+		return count, nil
+	}
+
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := fmt.Sprintf(`
+		WITH retried AS (
+			UPDATE push_queue
+			SET status = $%d, attempts = 0, scheduled_at = NOW(), updated_at = NOW()
+			%s
+			RETURNING id
+		), requeued AS (
+			DELETE FROM push_dead_letter WHERE id IN (SELECT id FROM retried)
+		)
+		SELECT COUNT(*) FROM retried
+	`, argPos, whereClause)
+	args = append(args, model.StatusPending)
+
+	var retried int64
+	if err := tx.QueryRow(ctx, query, args...).Scan(&retried); err != nil {
+		return 0, fmt.Errorf("failed to retry failed tasks: %w", err)
+	}
+
+	if retried > 0 {
+		if err := notifyNewTask(ctx, tx, ""); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("failed to commit retry: %w", err)
+	}
+
+	return retried, nil
+}
+
+// statusError tells apart a missing task from one whose status does not
+// allow the requested change.
+func (r *QueueRepository) statusError(ctx context.Context, id uuid.UUID, cause error) error {
+	task, err := r.GetTaskByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: status is %s", cause, task.Status)
+}
+
+// GetPendingTasks claims up to limit due tasks for workerID, high priority
+// first. A non-empty priority restricts the claim to tasks of that priority.
+// Claimed tasks are leased until now+lease; if the worker dies before
+// reporting a result, the lease expires and ReleaseExpiredLeases returns the
+// task to the queue.
+func (r *QueueRepository) GetPendingTasks(ctx context.Context, workerID string, limit int, lease time.Duration, priority string) ([]*model.PushQueueTask, error) {
+	query := `
+		UPDATE push_queue
+		SET status = $1,
+		    claimed_by = $2,
+		    lease_expires_at = NOW() + $3::interval,
+		    updated_at = NOW()
 		WHERE id IN (
 			SELECT id FROM push_queue
-			WHERE status = $2
+			WHERE status = $4
 			  AND scheduled_at <= NOW()
 			  AND attempts < max_attempts
-			ORDER BY scheduled_at ASC
-			LIMIT $3
+			  AND ($6 = '' OR priority = $6)
+			ORDER BY CASE WHEN priority = 'high' THEN 0 ELSE 1 END, scheduled_at ASC
+			LIMIT $5
 			FOR UPDATE SKIP LOCKED
 		)
-		RETURNING id, token, title, body, data, priority, client_id,
-		          status, attempts, max_attempts, error_message, fcm_message_id,
-		          scheduled_at, created_at, updated_at
-	`
+		RETURNING ` + taskColumns
 
-	rows, err := r.db.Pool.Query(ctx, query, model.StatusProcessing, model.StatusPending, limit)
+	rows, err := r.db.Pool.Query(ctx, query,
+		model.StatusProcessing, workerID, lease, model.StatusPending, limit, priority,
+	)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get pending tasks: %w", err)
 	}
@@ -119,37 +566,99 @@ func (r *QueueRepository) GetPendingTasks(ctx context.Context, limit int) ([]*mo
 
 	var tasks []*model.PushQueueTask
 	for rows.Next() {
-		task := &model.PushQueueTask{}
-		err := rows.Scan(
-			&task.ID, &task.Token, &task.Title, &task.Body, &task.Data, &task.Priority, &task.ClientID,
-			&task.Status, &task.Attempts, &task.MaxAttempts, &task.ErrorMessage, &task.FCMMessageID,
-			&task.ScheduledAt, &task.CreatedAt, &task.UpdatedAt,
-		)
+		task, err := scanTask(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan task: %w", err)
 		}
 		tasks = append(tasks, task)
 	}
 
-	return tasks, nil
+	return tasks, rows.Err()
 }
 
-func (r *QueueRepository) UpdateTaskSuccess(ctx context.Context, id uuid.UUID, messageID string) error {
+// ReleaseExpiredLeases returns tasks whose processing lease has expired back to
+// pending. The interrupted run counts as an attempt, so a task that keeps
+// crashing its worker ends up failed (and dead-lettered) instead of looping
+// forever. Each release is logged in push_attempts.
+func (r *QueueRepository) ReleaseExpiredLeases(ctx context.Context) (int64, error) {
 	query := `
-		UPDATE push_queue
-		SET status = $1, fcm_message_id = $2, updated_at = NOW()
-		WHERE id = $3
+		WITH expired AS (
+			SELECT id, claimed_by, updated_at AS claimed_at
+			FROM push_queue
+			WHERE status = $3
+			  AND lease_expires_at < NOW()
+			FOR UPDATE SKIP LOCKED
+		), released AS (
+			UPDATE push_queue AS q
+			SET attempts = q.attempts + 1,
+			    status = CASE WHEN q.attempts + 1 < q.max_attempts THEN $1 ELSE $2 END,
+			    error_message = 'lease expired while held by ' || COALESCE(e.claimed_by, 'unknown worker'),
+			    error_code = $5,
+			    claimed_by = NULL,
+			    lease_expires_at = NULL,
+			    updated_at = NOW()
+			FROM expired AS e
+			WHERE q.id = e.id
+			RETURNING q.*, e.claimed_by AS expired_claim, e.claimed_at
+		), dead AS (` + fmt.Sprintf(deadLetterInsert, "released WHERE status = $2") + `), logged AS (
+			INSERT INTO push_attempts (
+				task_id, attempt, worker_id, started_at, finished_at, latency_ms, outcome, error_code, error_message
+			)
+			SELECT id, attempts, COALESCE(expired_claim, ''), claimed_at, NOW(),
+			       (EXTRACT(EPOCH FROM NOW() - claimed_at) * 1000)::BIGINT,
+			       $4, error_code, error_message
+			FROM released
+		)
+		SELECT COUNT(*) FROM released
 	`
 
-	_, err := r.db.Pool.Exec(ctx, query, model.StatusSuccess, messageID, id)
+	var released int64
+	err := r.db.Pool.QueryRow(ctx, query,
+		model.StatusPending, model.StatusFailed, model.StatusProcessing, model.AttemptLeaseExpired,
+		model.ErrorCodeLeaseExpired,
+	).Scan(&released)
 	if err != nil {
-		return fmt.Errorf("failed to update task success: %w", err)
+		return 0, fmt.Errorf("failed to release expired leases: %w", err)
 	}
 
-	return nil
+	return released, nil
 }
 
-func (r *QueueRepository) UpdateTaskFailure(ctx context.Context, id uuid.UUID, errorMsg string, nextRetry *time.Time) error {
+// UpdateTasksSuccess marks many tasks still claimed by claimID as sent in one
+// statement and returns how many were. messageIDs maps task IDs to the FCM
+// message ID returned for them.
+func (r *QueueRepository) UpdateTasksSuccess(ctx context.Context, claimID string, messageIDs map[uuid.UUID]string) (int64, error) {
+	if len(messageIDs) == 0 {
+		return 0, nil
+	}
+
+	ids := make([]string, 0, len(messageIDs))
+	fcmIDs := make([]string, 0, len(messageIDs))
+	for id, messageID := range messageIDs {
+		ids = append(ids, id.String())
+		fcmIDs = append(fcmIDs, messageID)
+	}
+
+	query := `
+		UPDATE push_queue AS q
+		SET status = $1, fcm_message_id = u.message_id,
+		    claimed_by = NULL, lease_expires_at = NULL,
+		    updated_at = NOW()
+		FROM unnest($2::uuid[], $3::text[]) AS u(id, message_id)
+		WHERE q.id = u.id
+		  AND q.status = $4
+		  AND q.claimed_by = $5
+	`
+
+	result, err := r.db.Pool.Exec(ctx, query, model.StatusSuccess, ids, fcmIDs, model.StatusProcessing, claimID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update tasks success: %w", err)
+	}
+
+	return result.RowsAffected(), nil
+}
+
+func (r *QueueRepository) UpdateTaskFailure(ctx context.Context, id uuid.UUID, claimID, errorMsg, errorCode string, nextRetry *time.Time) error {
 	var query string
 	var args []interface{}
 
@@ -158,32 +667,221 @@ func (r *QueueRepository) UpdateTaskFailure(ctx context.Context, id uuid.UUID, e
 			UPDATE push_queue
 			SET attempts = attempts + 1,
 			    error_message = $1,
-			    scheduled_at = $2,
-			    status = $3,
+			    error_code = $2,
+			    scheduled_at = $3,
+			    status = $4,
+			    claimed_by = NULL,
+			    lease_expires_at = NULL,
 			    updated_at = NOW()
-			WHERE id = $4
+			WHERE id = $5
+			  AND status = $6
+			  AND claimed_by = $7
 		`
-		args = []interface{}{errorMsg, *nextRetry, model.StatusPending, id}
+		args = []interface{}{errorMsg, errorCode, *nextRetry, model.StatusPending, id, model.StatusProcessing, claimID}
 	} else {
+		// The task is out of attempts: mark it failed and copy it to the
+		// dead-letter table in the same statement.
 		query = `
-			UPDATE push_queue
-			SET attempts = attempts + 1,
-			    error_message = $1,
-			    status = $2,
-			    updated_at = NOW()
-			WHERE id = $3
-		`
-		args = []interface{}{errorMsg, model.StatusFailed, id}
+			WITH failed AS (
+				UPDATE push_queue
+				SET attempts = attempts + 1,
+				    error_message = $1,
+				    error_code = $2,
+				    status = $3,
+				    claimed_by = NULL,
+				    lease_expires_at = NULL,
+				    updated_at = NOW()
+				WHERE id = $4
+				  AND status = $5
+				  AND claimed_by = $6
+				RETURNING *
+			)` + fmt.Sprintf(deadLetterInsert, "failed")
+		args = []interface{}{errorMsg, errorCode, model.StatusFailed, id, model.StatusProcessing, claimID}
 	}
 
-	_, err := r.db.Pool.Exec(ctx, query, args...)
+	result, err := r.db.Pool.Exec(ctx, query, args...)
 	if err != nil {
 		return fmt.Errorf("failed to update task failure: %w", err)
 	}
 
+	if result.RowsAffected() == 0 {
+		return ErrLeaseLost
+	}
+
+	return nil
+}
+
+// SkipTasks marks tasks still claimed by claimID as skipped without sending
+// them and returns how many were.
+func (r *QueueRepository) SkipTasks(ctx context.Context, ids []uuid.UUID, claimID, errorMsg, errorCode string) (int64, error) {
+	skipped, err := r.closeUnsentTasks(ctx, ids, claimID, model.StatusSkipped, errorMsg, errorCode)
+	if err != nil {
+		return 0, fmt.Errorf("failed to skip tasks: %w", err)
+	}
+	return skipped, nil
+}
+
+// ExpireTasks marks tasks still claimed by claimID whose expiry has passed as
+// expired without sending them and returns how many were.
+func (r *QueueRepository) ExpireTasks(ctx context.Context, ids []uuid.UUID, claimID string) (int64, error) {
+	expired, err := r.closeUnsentTasks(ctx, ids, claimID, model.StatusExpired, "message expired before it could be sent", model.ErrorCodeExpired)
+	if err != nil {
+		return 0, fmt.Errorf("failed to expire tasks: %w", err)
+	}
+	return expired, nil
+}
+
+func (r *QueueRepository) closeUnsentTasks(ctx context.Context, ids []uuid.UUID, claimID string, status model.QueueStatus, errorMsg, errorCode string) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	query := `
+		UPDATE push_queue
+		SET status = $1,
+		    error_message = $2,
+		    error_code = $3,
+		    claimed_by = NULL,
+		    lease_expires_at = NULL,
+		    updated_at = NOW()
+		WHERE id = ANY($4::uuid[])
+		  AND status = $5
+		  AND claimed_by = $6
+	`
+
+	taskIDs := make([]string, len(ids))
+	for i, id := range ids {
+		taskIDs[i] = id.String()
+	}
+
+	result, err := r.db.Pool.Exec(ctx, query, status, errorMsg, errorCode, taskIDs, model.StatusProcessing, claimID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected(), nil
+}
+
+// ExpireAfterFailure counts a failed attempt and marks the task expired,
+// for a retry that would only be due after the task's expiry. The send
+// error is kept in error_message.
+func (r *QueueRepository) ExpireAfterFailure(ctx context.Context, id uuid.UUID, claimID, errorMsg string) error {
+	query := `
+		UPDATE push_queue
+		SET attempts = attempts + 1,
+		    error_message = $1,
+		    error_code = $2,
+		    status = $3,
+		    claimed_by = NULL,
+		    lease_expires_at = NULL,
+		    updated_at = NOW()
+		WHERE id = $4
+		  AND status = $5
+		  AND claimed_by = $6
+	`
+
+	result, err := r.db.Pool.Exec(ctx, query, errorMsg, model.ErrorCodeExpired, model.StatusExpired, id, model.StatusProcessing, claimID)
+	if err != nil {
+		return fmt.Errorf("failed to expire task: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrLeaseLost
+	}
+
+	return nil
+}
+
+// RequeueTask returns a claimed task to pending at scheduledAt without
+// counting an attempt, for sends that never got a fair chance to succeed.
+func (r *QueueRepository) RequeueTask(ctx context.Context, id uuid.UUID, claimID, errorMsg, errorCode string, scheduledAt time.Time) error {
+	query := `
+		UPDATE push_queue
+		SET status = $1,
+		    error_message = $2,
+		    error_code = $3,
+		    scheduled_at = $4,
+		    claimed_by = NULL,
+		    lease_expires_at = NULL,
+		    updated_at = NOW()
+		WHERE id = $5
+		  AND status = $6
+		  AND claimed_by = $7
+	`
+
+	result, err := r.db.Pool.Exec(ctx, query, model.StatusPending, errorMsg, errorCode, scheduledAt, id, model.StatusProcessing, claimID)
+	if err != nil {
+		return fmt.Errorf("failed to requeue task: %w", err)
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrLeaseLost
+	}
+
 	return nil
 }
 
+// ReleaseClaimedTasks returns every task still being processed under a claim
+// ID starting with claimPrefix to pending, without counting an attempt. It is
+// used on shutdown so tasks do not wait for their lease to expire.
+func (r *QueueRepository) ReleaseClaimedTasks(ctx context.Context, claimPrefix string) (int64, error) {
+	query := `
+		UPDATE push_queue
+		SET status = $1,
+		    scheduled_at = NOW(),
+		    claimed_by = NULL,
+		    lease_expires_at = NULL,
+		    updated_at = NOW()
+		WHERE status = $2
+		  AND starts_with(claimed_by, $3)
+	`
+
+	result, err := r.db.Pool.Exec(ctx, query, model.StatusPending, model.StatusProcessing, claimPrefix)
+	if err != nil {
+		return 0, fmt.Errorf("failed to release claimed tasks: %w", err)
+	}
+
+	if result.RowsAffected() > 0 {
+		if _, err := r.db.Pool.Exec(ctx, "SELECT pg_notify($1, '')", NewTaskChannel); err != nil {
+			return result.RowsAffected(), fmt.Errorf("failed to notify workers: %w", err)
+		}
+	}
+
+	return result.RowsAffected(), nil
+}
+
+// ReleaseTasks returns tasks still claimed by claimID to the queue without
+// counting an attempt, for sends that never started.
+func (r *QueueRepository) ReleaseTasks(ctx context.Context, ids []uuid.UUID, claimID string) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	query := `
+		UPDATE push_queue
+		SET status = $1,
+		    scheduled_at = NOW(),
+		    claimed_by = NULL,
+		    lease_expires_at = NULL,
+		    updated_at = NOW()
+		WHERE id = ANY($2)
+		  AND status = $3
+		  AND claimed_by = $4
+	`
+
+	result, err := r.db.Pool.Exec(ctx, query, model.StatusPending, ids, model.StatusProcessing, claimID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to release tasks: %w", err)
+	}
+
+	if result.RowsAffected() > 0 {
+		if _, err := r.db.Pool.Exec(ctx, "SELECT pg_notify($1, '')", NewTaskChannel); err != nil {
+			return result.RowsAffected(), fmt.Errorf("failed to notify workers: %w", err)
+		}
+	}
+
+	return result.RowsAffected(), nil
+}
+
 func (r *QueueRepository) GetHistory(ctx context.Context, req *model.QueueHistoryRequest) (*model.QueueHistoryResponse, error) {
 	var conditions []string
 	var args []interface{}
@@ -201,6 +899,12 @@ func (r *QueueRepository) GetHistory(ctx context.Context, req *model.QueueHistor
 		argPos++
 	}
 
+	if req.TargetType != "" {
+		conditions = append(conditions, fmt.Sprintf("target_type = $%d", argPos))
+		args = append(args, req.TargetType)
+		argPos++
+	}
+
 	if req.StartDate != nil {
 		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", argPos))
 		args = append(args, *req.StartDate)
@@ -233,8 +937,10 @@ func (r *QueueRepository) GetHistory(ctx context.Context, req *model.QueueHistor
 	}
 
 	query := fmt.Sprintf(`
-		SELECT id, token, title, body, client_id, status, attempts, max_attempts,
-		       error_message, fcm_message_id, created_at, updated_at
+		SELECT id, target_type, COALESCE(token, ''), COALESCE(topic, ''), COALESCE(condition, ''),
+		       message_type, COALESCE(title, ''), COALESCE(body, ''), expires_at, COALESCE(collapse_key, ''),
+		       client_id, status, attempts, max_attempts,
+		       error_message, error_code, fcm_message_id, send_at, scheduled_at, created_at, updated_at
 		FROM push_queue
 		%s
 		ORDER BY created_at DESC
@@ -253,9 +959,11 @@ func (r *QueueRepository) GetHistory(ctx context.Context, req *model.QueueHistor
 	for rows.Next() {
 		task := model.QueueTaskResponse{}
 		err := rows.Scan(
-			&task.ID, &task.Token, &task.Title, &task.Body, &task.ClientID,
+			&task.ID, &task.TargetType, &task.Token, &task.Topic, &task.Condition,
+			&task.MessageType, &task.Title, &task.Body, &task.ExpiresAt, &task.CollapseKey, &task.ClientID,
 			&task.Status, &task.Attempts, &task.MaxAttempts,
-			&task.ErrorMessage, &task.FCMMessageID, &task.CreatedAt, &task.UpdatedAt,
+			&task.ErrorMessage, &task.ErrorCode, &task.FCMMessageID, &task.SendAt, &task.ScheduledAt,
+			&task.CreatedAt, &task.UpdatedAt,
 		)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan task: %w", err)
@@ -278,6 +986,10 @@ func (r *QueueRepository) GetStats(ctx context.Context) (*model.QueueStatsRespon
 			COUNT(*) FILTER (WHERE status = 'processing') as processing_count,
 			COUNT(*) FILTER (WHERE status = 'success') as success_count,
 			COUNT(*) FILTER (WHERE status = 'failed') as failed_count,
+			COUNT(*) FILTER (WHERE status = 'cancelled') as cancelled_count,
+			COUNT(*) FILTER (WHERE status = 'skipped') as skipped_count,
+			COUNT(*) FILTER (WHERE status = 'expired') as expired_count,
+			COUNT(*) FILTER (WHERE status = 'superseded') as superseded_count,
 			COUNT(*) as total_count
 		FROM push_queue
 	`
@@ -288,6 +1000,10 @@ func (r *QueueRepository) GetStats(ctx context.Context) (*model.QueueStatsRespon
 		&stats.ProcessingCount,
 		&stats.SuccessCount,
 		&stats.FailedCount,
+		&stats.CancelledCount,
+		&stats.SkippedCount,
+		&stats.ExpiredCount,
+		&stats.SupersededCount,
 		&stats.TotalCount,
 	)
 
@@ -302,7 +1018,7 @@ func (r *QueueRepository) CleanupOldTasks(ctx context.Context, olderThan time.Du
 	query := `
 		DELETE FROM push_queue
 		WHERE created_at < $1
-		  AND status IN ('success', 'failed')
+		  AND status IN ('success', 'failed', 'cancelled', 'skipped', 'expired', 'superseded')
 	`
 
 	cutoffTime := time.Now().Add(-olderThan)
@@ -313,23 +1029,45 @@ func (r *QueueRepository) CleanupOldTasks(ctx context.Context, olderThan time.Du
 
 	return result.RowsAffected(), nil
 }
-func (r *QueueRepository) IsDuplicateTask(ctx context.Context, token, title, body string, interval time.Duration) (bool, error) {
+
+// FindRecentDuplicate returns the latest live task of the same client with the
+// same target and content as req created within interval, or nil if there is
+// none. Cancelled and superseded tasks never count as duplicates.
+func (r *QueueRepository) FindRecentDuplicate(ctx context.Context, req *model.CreateQueueTaskRequest, interval time.Duration) (*model.PushQueueTask, error) {
 	query := `
-		SELECT EXISTS(
-			SELECT 1 FROM push_queue
-			WHERE token = $1 
-			  AND title = $2 
-			  AND body = $3 
-			  AND created_at > $4
-		)
+		SELECT ` + taskColumns + `
+		FROM push_queue
+		WHERE client_id = $1
+		  AND target_type = $2
+		  AND token IS NOT DISTINCT FROM NULLIF($3, '')
+		  AND topic IS NOT DISTINCT FROM NULLIF($4, '')
+		  AND condition IS NOT DISTINCT FROM NULLIF($5, '')
+		  AND message_type = $6
+		  AND title IS NOT DISTINCT FROM NULLIF($7, '')
+		  AND body IS NOT DISTINCT FROM NULLIF($8, '')
+		  AND data IS NOT DISTINCT FROM $9::jsonb
+		  AND status NOT IN ('cancelled', 'superseded')
+		  AND created_at > $10
+		ORDER BY created_at DESC
+		LIMIT 1
 	`
 
+	messageType := req.MessageType
+	if messageType == "" {
+		messageType = model.MessageTypeNotification
+	}
+
 	cutoffTime := time.Now().Add(-interval)
-	var exists bool
-	err := r.db.Pool.QueryRow(ctx, query, token, title, body, cutoffTime).Scan(&exists)
+	task, err := scanTask(r.db.Pool.QueryRow(ctx, query,
+		req.ClientID, req.TargetType(), req.Token, req.Topic, req.Condition,
+		messageType, req.Title, req.Body, model.JSONMap(req.Data), cutoffTime,
+	))
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	}
 	if err != nil {
-		return false, fmt.Errorf("failed to check for duplicates: %w", err)
+		return nil, fmt.Errorf("failed to check for duplicates: %w", err)
 	}
 
-	return exists, nil
+	return task, nil
 }
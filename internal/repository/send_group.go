@@ -0,0 +1,204 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/galyym/fcm_push/internal/model"
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+var ErrSendGroupNotFound = errors.New("send group not found")
+
+const sendGroupColumns = `
+	id, client_id, idempotency_key, COALESCE(title, ''), COALESCE(body, ''), token_count, created_at
+`
+
+func scanSendGroup(row rowScanner) (*model.SendGroup, error) {
+	group := &model.SendGroup{}
+	err := row.Scan(
+		&group.ID, &group.ClientID, &group.IdempotencyKey, &group.Title, &group.Body, &group.TokenCount, &group.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return group, nil
+}
+
+// CreateSendGroup creates a group and one pending task per token, built from
+// the template request. With a collapse key, each task supersedes the pending
+// tasks it replaces, as in CreateTask. With an idempotency key, a group the client already
+// created with that key within retention is returned instead and created is
+// false.
+func (r *QueueRepository) CreateSendGroup(ctx context.Context, template *model.CreateQueueTaskRequest, tokens []string, retention time.Duration) (group *model.SendGroup, created bool, err error) {
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	group = &model.SendGroup{
+		ID:         uuid.New(),
+		ClientID:   template.ClientID,
+		Title:      template.Title,
+		Body:       template.Body,
+		TokenCount: len(tokens),
+	}
+
+	if template.IdempotencyKey != "" {
+		group.IdempotencyKey = &template.IdempotencyKey
+
+		releaseQuery := `
+			UPDATE push_send_groups
+			SET idempotency_key = NULL
+			WHERE client_id = $1 AND idempotency_key = $2 AND created_at < $3
+		`
+		if _, err := tx.Exec(ctx, releaseQuery, template.ClientID, template.IdempotencyKey, time.Now().Add(-retention)); err != nil {
+			return nil, false, fmt.Errorf("failed to release expired idempotency key: %w", err)
+		}
+	}
+
+	query := `
+		INSERT INTO push_send_groups (id, client_id, idempotency_key, title, body, token_count)
+		VALUES ($1, $2, $3, NULLIF($4, ''), NULLIF($5, ''), $6)
+		ON CONFLICT (client_id, idempotency_key) WHERE idempotency_key IS NOT NULL DO NOTHING
+		RETURNING created_at
+	`
+
+	err = tx.QueryRow(ctx, query,
+		group.ID, group.ClientID, group.IdempotencyKey, group.Title, group.Body, group.TokenCount,
+	).Scan(&group.CreatedAt)
+	if err == pgx.ErrNoRows {
+		existing, err := scanSendGroup(tx.QueryRow(ctx,
+			`SELECT `+sendGroupColumns+` FROM push_send_groups WHERE client_id = $1 AND idempotency_key = $2`,
+			template.ClientID, template.IdempotencyKey,
+		))
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to get send group by idempotency key: %w", err)
+		}
+		return existing, false, tx.Commit(ctx)
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create send group: %w", err)
+	}
+
+	// Group tasks are found through group_id, so the per-task idempotency key stays unset.
+	taskReq := *template
+	taskReq.IdempotencyKey = ""
+
+	columns := []string{
+		"id", "group_id", "target_type", "token", "message_type", "title", "body", "data", "platform_options", "priority",
+		"expires_at", "collapse_key", "client_id",
+		"status", "attempts", "max_attempts", "retry_policy", "send_at", "scheduled_at", "created_at", "updated_at",
+	}
+
+	_, err = tx.CopyFrom(ctx, pgx.Identifier{"push_queue"}, columns,
+		pgx.CopyFromSlice(len(tokens), func(i int) ([]interface{}, error) {
+			taskReq.Token = tokens[i]
+			t := newTask(&taskReq)
+			return []interface{}{
+				t.ID, group.ID, t.TargetType, t.Token, t.MessageType, nullIfEmpty(t.Title), nullIfEmpty(t.Body), t.Data, t.Platform, t.Priority,
+				t.ExpiresAt, nullIfEmpty(t.CollapseKey), t.ClientID,
+				t.Status, t.Attempts, t.MaxAttempts, t.RetryPolicy, t.SendAt, t.ScheduledAt, t.CreatedAt, t.UpdatedAt,
+			}, nil
+		}),
+	)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create group tasks: %w", err)
+	}
+
+	if template.CollapseKey != "" {
+		supersedeQuery := `
+			UPDATE push_queue old
+			SET status = $1,
+			    error_message = 'superseded by task ' || new.id,
+			    error_code = $2,
+			    updated_at = NOW()
+			FROM push_queue new
+			WHERE new.group_id = $3
+			  AND old.status = $4
+			  AND old.client_id = new.client_id
+			  AND old.collapse_key = new.collapse_key
+			  AND old.target_type = new.target_type
+			  AND old.token = new.token
+			  AND old.id <> new.id
+		`
+		_, err := tx.Exec(ctx, supersedeQuery, model.StatusSuperseded, model.ErrorCodeSuperseded, group.ID, model.StatusPending)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to supersede pending tasks: %w", err)
+		}
+	}
+
+	if err := notifyNewTask(ctx, tx, group.ID.String()); err != nil {
+		return nil, false, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, false, fmt.Errorf("failed to commit send group: %w", err)
+	}
+
+	return group, true, nil
+}
+
+// GetSendGroup returns a group with the number of its tasks in each status.
+func (r *QueueRepository) GetSendGroup(ctx context.Context, id uuid.UUID) (*model.SendGroupResponse, error) {
+	query := `
+		SELECT g.id, g.client_id, COALESCE(g.title, ''), COALESCE(g.body, ''), g.token_count, g.created_at,
+			COUNT(q.id) FILTER (WHERE q.status = 'pending'),
+			COUNT(q.id) FILTER (WHERE q.status = 'processing'),
+			COUNT(q.id) FILTER (WHERE q.status = 'success'),
+			COUNT(q.id) FILTER (WHERE q.status = 'failed'),
+			COUNT(q.id) FILTER (WHERE q.status = 'cancelled'),
+			COUNT(q.id) FILTER (WHERE q.status = 'skipped'),
+			COUNT(q.id) FILTER (WHERE q.status = 'expired'),
+			COUNT(q.id) FILTER (WHERE q.status = 'superseded')
+		FROM push_send_groups g
+		LEFT JOIN push_queue q ON q.group_id = g.id
+		WHERE g.id = $1
+		GROUP BY g.id
+	`
+
+	group := &model.SendGroupResponse{}
+	err := r.db.Pool.QueryRow(ctx, query, id).Scan(
+		&group.GroupID, &group.ClientID, &group.Title, &group.Body, &group.TokenCount, &group.CreatedAt,
+		&group.PendingCount, &group.ProcessingCount, &group.SuccessCount,
+		&group.FailedCount, &group.CancelledCount, &group.SkippedCount, &group.ExpiredCount, &group.SupersededCount,
+	)
+	if err == pgx.ErrNoRows {
+		return nil, ErrSendGroupNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get send group: %w", err)
+	}
+
+	group.Completed = group.PendingCount == 0 && group.ProcessingCount == 0
+	return group, nil
+}
+
+// CleanupSendGroups deletes groups older than olderThan whose tasks have all
+// been cleaned up.
+func (r *QueueRepository) CleanupSendGroups(ctx context.Context, olderThan time.Duration) (int64, error) {
+	query := `
+		DELETE FROM push_send_groups g
+		WHERE g.created_at < $1
+		  AND NOT EXISTS (SELECT 1 FROM push_queue q WHERE q.group_id = g.id)
+	`
+
+	cutoffTime := time.Now().Add(-olderThan)
+	result, err := r.db.Pool.Exec(ctx, query, cutoffTime)
+	if err != nil {
+		return 0, fmt.Errorf("failed to cleanup send groups: %w", err)
+	}
+
+	return result.RowsAffected(), nil
+}
+
+func nullIfEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/galyym/fcm_push/internal/database"
+	"github.com/galyym/fcm_push/internal/model"
+)
+
+const tokenStatusColumns = `
+	token_hash, token, status, error_code, error_message, failure_count, first_failed_at, last_failed_at
+`
+
+// HashToken returns the key token_status uses for token.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func scanTokenStatus(row rowScanner) (*model.TokenStatus, error) {
+	status := &model.TokenStatus{}
+	err := row.Scan(
+		&status.TokenHash, &status.Token, &status.Status, &status.ErrorCode, &status.ErrorMessage,
+		&status.FailureCount, &status.FirstFailedAt, &status.LastFailedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+type TokenRepository struct {
+	db *database.DB
+}
+
+func NewTokenRepository(db *database.DB) *TokenRepository {
+	return &TokenRepository{db: db}
+}
+
+// MarkInvalid records failures as dead tokens and deactivates the devices
+// that hold them, so pushes to their users skip them.
+func (r *TokenRepository) MarkInvalid(ctx context.Context, failures []model.TokenFailure) error {
+	if len(failures) == 0 {
+		return nil
+	}
+
+	// One row per token: ON CONFLICT cannot update the same row twice in a statement.
+	seen := make(map[string]bool, len(failures))
+	var hashes, tokens, codes, messages []string
+	for _, failure := range failures {
+		hash := HashToken(failure.Token)
+		if seen[hash] {
+			continue
+		}
+		seen[hash] = true
+		hashes = append(hashes, hash)
+		tokens = append(tokens, failure.Token)
+		codes = append(codes, failure.ErrorCode)
+		messages = append(messages, failure.ErrorMessage)
+	}
+
+	tx, err := r.db.Pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	query := `
+		INSERT INTO token_status (token_hash, token, status, error_code, error_message)
+		SELECT u.hash, u.token, $5, u.code, u.message
+		FROM unnest($1::text[], $2::text[], $3::text[], $4::text[]) AS u(hash, token, code, message)
+		ON CONFLICT (token_hash) DO UPDATE
+		SET status = EXCLUDED.status,
+		    error_code = EXCLUDED.error_code,
+		    error_message = EXCLUDED.error_message,
+		    failure_count = token_status.failure_count + 1,
+		    last_failed_at = NOW()
+	`
+	if _, err := tx.Exec(ctx, query, hashes, tokens, codes, messages, model.TokenStatusInvalid); err != nil {
+		return fmt.Errorf("failed to record invalid tokens: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `UPDATE devices SET active = FALSE, updated_at = NOW() WHERE token = ANY($1) AND active`, tokens); err != nil {
+		return fmt.Errorf("failed to deactivate devices: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit invalid tokens: %w", err)
+	}
+
+	return nil
+}
+
+// FindInvalid returns the statuses of those tokens that are known to be dead,
+// keyed by token.
+func (r *TokenRepository) FindInvalid(ctx context.Context, tokens []string) (map[string]*model.TokenStatus, error) {
+	invalid := make(map[string]*model.TokenStatus)
+	if len(tokens) == 0 {
+		return invalid, nil
+	}
+
+	hashes := make([]string, len(tokens))
+	for i, token := range tokens {
+		hashes[i] = HashToken(token)
+	}
+
+	query := `SELECT ` + tokenStatusColumns + ` FROM token_status WHERE token_hash = ANY($1) AND status = $2`
+
+	rows, err := r.db.Pool.Query(ctx, query, hashes, model.TokenStatusInvalid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find invalid tokens: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		status, err := scanTokenStatus(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan token status: %w", err)
+		}
+		invalid[status.Token] = status
+	}
+
+	return invalid, rows.Err()
+}
+
+// ListInvalid returns tokens that failed since the given time, newest first.
+func (r *TokenRepository) ListInvalid(ctx context.Context, req *model.InvalidTokensRequest) (*model.InvalidTokensResponse, error) {
+	var total int
+	err := r.db.Pool.QueryRow(ctx,
+		`SELECT COUNT(*) FROM token_status WHERE status = $1 AND last_failed_at >= $2`,
+		model.TokenStatusInvalid, *req.Since,
+	).Scan(&total)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get total count: %w", err)
+	}
+
+	if req.Limit <= 0 {
+		req.Limit = 100
+	}
+	if req.Offset < 0 {
+		req.Offset = 0
+	}
+
+	query := `
+		SELECT ` + tokenStatusColumns + `
+		FROM token_status
+		WHERE status = $1 AND last_failed_at >= $2
+		ORDER BY last_failed_at DESC
+		LIMIT $3 OFFSET $4
+	`
+
+	rows, err := r.db.Pool.Query(ctx, query, model.TokenStatusInvalid, *req.Since, req.Limit, req.Offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invalid tokens: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := []model.TokenStatus{}
+	for rows.Next() {
+		status, err := scanTokenStatus(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan token status: %w", err)
+		}
+		tokens = append(tokens, *status)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list invalid tokens: %w", err)
+	}
+
+	return &model.InvalidTokensResponse{
+		Tokens: tokens,
+		Total:  total,
+		Limit:  req.Limit,
+		Offset: req.Offset,
+	}, nil
+}
+
+// IsInvalid reports the status of token if it is known to be dead, or nil.
+func (r *TokenRepository) IsInvalid(ctx context.Context, token string) (*model.TokenStatus, error) {
+	invalid, err := r.FindInvalid(ctx, []string{token})
+	if err != nil {
+		return nil, err
+	}
+	return invalid[token], nil
+}
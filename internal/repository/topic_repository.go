@@ -0,0 +1,67 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/galyym/fcm_push/internal/database"
+)
+
+type TopicRepository struct {
+	db *database.DB
+}
+
+func NewTopicRepository(db *database.DB) *TopicRepository {
+	return &TopicRepository{db: db}
+}
+
+func (r *TopicRepository) AddSubscriptions(ctx context.Context, topic string, tokens []string) error {
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	query := `
+		INSERT INTO topic_subscriptions (token, topic)
+		SELECT DISTINCT token, $2 FROM unnest($1::text[]) AS token
+		ON CONFLICT (token, topic) DO NOTHING
+	`
+
+	if _, err := r.db.Pool.Exec(ctx, query, tokens, topic); err != nil {
+		return fmt.Errorf("failed to add topic subscriptions: %w", err)
+	}
+
+	return nil
+}
+
+func (r *TopicRepository) RemoveSubscriptions(ctx context.Context, topic string, tokens []string) error {
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	query := `DELETE FROM topic_subscriptions WHERE topic = $1 AND token = ANY($2)`
+
+	if _, err := r.db.Pool.Exec(ctx, query, topic, tokens); err != nil {
+		return fmt.Errorf("failed to remove topic subscriptions: %w", err)
+	}
+
+	return nil
+}
+
+func (r *TopicRepository) TopicsForToken(ctx context.Context, token string) ([]string, error) {
+	rows, err := r.db.Pool.Query(ctx, `SELECT topic FROM topic_subscriptions WHERE token = $1 ORDER BY topic`, token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get topics: %w", err)
+	}
+	defer rows.Close()
+
+	topics := []string{}
+	for rows.Next() {
+		var topic string
+		if err := rows.Scan(&topic); err != nil {
+			return nil, fmt.Errorf("failed to scan topic: %w", err)
+		}
+		topics = append(topics, topic)
+	}
+
+	return topics, rows.Err()
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/galyym/fcm_push/internal/model"
+	"github.com/galyym/fcm_push/internal/repository"
+	"github.com/google/uuid"
+)
+
+type DeadLetterService struct {
+	repo *repository.QueueRepository
+}
+
+func NewDeadLetterService(repo *repository.QueueRepository) *DeadLetterService {
+	return &DeadLetterService{repo: repo}
+}
+
+func (s *DeadLetterService) List(ctx context.Context, req *model.DeadLetterListRequest) (*model.DeadLetterListResponse, error) {
+	return s.repo.ListDeadLetters(ctx, req)
+}
+
+// Get returns an entry together with every recorded attempt of its task.
+func (s *DeadLetterService) Get(ctx context.Context, id uuid.UUID) (*model.DeadLetterEntry, error) {
+	entry, err := s.repo.GetDeadLetter(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	entry.AttemptHistory, err = s.repo.GetAttempts(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
+
+// Requeue sends a dead-lettered task again with a fresh set of attempts.
+func (s *DeadLetterService) Requeue(ctx context.Context, id uuid.UUID) (*model.QueueTaskResponse, error) {
+	task, err := s.repo.RequeueDeadLetter(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to requeue dead letter: %w", err)
+	}
+
+	log.Printf("Dead letter %s requeued", task.ID)
+	return taskResponse(task), nil
+}
+
+func (s *DeadLetterService) Delete(ctx context.Context, id uuid.UUID) error {
+	if err := s.repo.DeleteDeadLetter(ctx, id); err != nil {
+		return fmt.Errorf("failed to delete dead letter: %w", err)
+	}
+
+	log.Printf("Dead letter %s deleted", id)
+	return nil
+}
+
+func (s *DeadLetterService) Purge(ctx context.Context, req *model.DeadLetterPurgeRequest) (*model.DeadLetterPurgeResponse, error) {
+	purged, err := s.repo.PurgeDeadLetters(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to purge dead letters: %w", err)
+	}
+
+	log.Printf("Purged %d dead letters (client: %q, error code: %q, before %s)",
+		purged, req.ClientID, req.ErrorCode, req.Before.Format(time.RFC3339))
+	return &model.DeadLetterPurgeResponse{PurgedCount: purged}, nil
+}
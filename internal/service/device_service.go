@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/galyym/fcm_push/internal/model"
+	"github.com/galyym/fcm_push/internal/repository"
+	"github.com/google/uuid"
+)
+
+// ErrNoActiveDevices is returned when a push is sent to a user without active devices.
+var ErrNoActiveDevices = errors.New("user has no active devices")
+
+// maxUserIdempotencyKey leaves room for the ":<device id>" suffix added per
+// device when a push to a user is fanned out.
+const maxUserIdempotencyKey = 255 - 37
+
+type DeviceService struct {
+	repo         *repository.DeviceRepository
+	queueService *QueueService
+}
+
+func NewDeviceService(repo *repository.DeviceRepository, queueService *QueueService) *DeviceService {
+	return &DeviceService{
+		repo:         repo,
+		queueService: queueService,
+	}
+}
+
+func (s *DeviceService) Register(ctx context.Context, req *model.RegisterDeviceRequest) (*model.Device, error) {
+	if req.Timezone != "" {
+		if _, err := time.LoadLocation(req.Timezone); err != nil {
+			return nil, fmt.Errorf("%w: unknown timezone %q", ErrInvalidRequest, req.Timezone)
+		}
+	}
+
+	device, err := s.repo.Register(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to register device: %w", err)
+	}
+
+	log.Printf("Device %s registered for user %s (%s)", device.ID, device.UserID, device.Platform)
+	return device, nil
+}
+
+func (s *DeviceService) List(ctx context.Context, req *model.ListDevicesRequest) (*model.ListDevicesResponse, error) {
+	devices, err := s.repo.ListByUser(ctx, req.UserID, req.IncludeInactive)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.ListDevicesResponse{
+		UserID:  req.UserID,
+		Devices: devices,
+	}, nil
+}
+
+func (s *DeviceService) Delete(ctx context.Context, id uuid.UUID) error {
+	if err := s.repo.Delete(ctx, id); err != nil {
+		return fmt.Errorf("failed to delete device: %w", err)
+	}
+
+	log.Printf("Device %s deleted", id)
+	return nil
+}
+
+func (s *DeviceService) DeleteByToken(ctx context.Context, token string) error {
+	if err := s.repo.DeleteByToken(ctx, token); err != nil {
+		return fmt.Errorf("failed to delete device: %w", err)
+	}
+
+	log.Printf("Device with token %s deleted", maskToken(token))
+	return nil
+}
+
+// EnqueueForUser enqueues req once for every active device of userID. An
+// idempotency key is made unique per device by suffixing the device ID.
+func (s *DeviceService) EnqueueForUser(ctx context.Context, userID string, req *model.CreateQueueTaskRequest) ([]model.QueueTaskResponse, error) {
+	if len(req.IdempotencyKey) > maxUserIdempotencyKey {
+		return nil, fmt.Errorf("%w: idempotency key for a user push must be at most %d characters",
+			ErrInvalidRequest, maxUserIdempotencyKey)
+	}
+
+	devices, err := s.repo.ListByUser(ctx, userID, false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get devices: %w", err)
+	}
+	if len(devices) == 0 {
+		return nil, ErrNoActiveDevices
+	}
+
+	log.Printf("Enqueueing push for user %s to %d devices", userID, len(devices))
+
+	responses := make([]model.QueueTaskResponse, 0, len(devices))
+	for _, device := range devices {
+		deviceReq := *req
+		deviceReq.Token = device.Token
+		if req.IdempotencyKey != "" {
+			deviceReq.IdempotencyKey = req.IdempotencyKey + ":" + device.ID.String()
+		}
+
+		task, err := s.queueService.EnqueuePush(ctx, &deviceReq)
+		if errors.Is(err, ErrInvalidRequest) {
+			// The request itself is invalid, so every device would fail the same way.
+			return nil, err
+		}
+		if err != nil {
+			log.Printf("Failed to enqueue push for device %s: %v", device.ID, err)
+			responses = append(responses, model.QueueTaskResponse{
+				Status:       model.StatusFailed,
+				ClientID:     req.ClientID,
+				ErrorMessage: stringPtr(err.Error()),
+			})
+			continue
+		}
+
+		responses = append(responses, *task)
+	}
+
+	return responses, nil
+}
@@ -1,11 +1,6 @@
 package service
 
 import (
-	"context"
-	"fmt"
-	"log"
-
-	"firebase.google.com/go/v4/messaging"
 	"github.com/galyym/fcm_push/internal/model"
 	"github.com/galyym/fcm_push/pkg/fcm"
 )
@@ -20,86 +15,24 @@ func NewPushService(fcmClient *fcm.Client) *PushService {
 	}
 }
 
-func (s *PushService) SendPush(ctx context.Context, req *model.PushRequest) (*model.PushResponse, error) {
-	log.Printf("Sending push to client: %s, token: %s...", req.ClientID, maskToken(req.Token))
-	messageID, err := s.fcmClient.SendNotification(
-		ctx,
-		req.Token,
-		req.Title,
-		req.Body,
-		req.Data,
-		req.Priority,
-	)
-
-	if err != nil {
-		log.Printf("Failed to send push: %v", err)
-		return &model.PushResponse{
-			Success: false,
-			Error:   err.Error(),
-		}, err
-	}
-
-	log.Printf("Push sent successfully, message ID: %s", messageID)
-	return &model.PushResponse{
-		Success:   true,
-		MessageID: messageID,
-	}, nil
+// CircuitStatus reports the FCM circuit breaker state, or nil if none is configured.
+func (s *PushService) CircuitStatus() *model.CircuitBreakerStatus {
+	return circuitStatus(s.fcmClient.Breaker())
 }
 
-func (s *PushService) SendBatchPush(ctx context.Context, req *model.BatchPushRequest) (*model.BatchPushResponse, error) {
-	log.Printf("Sending batch push, count: %d", len(req.Notifications))
-
-	messages := make([]*messaging.Message, 0, len(req.Notifications))
-	for _, notification := range req.Notifications {
-		msg := &messaging.Message{
-			Token: notification.Token,
-			Notification: &messaging.Notification{
-				Title: notification.Title,
-				Body:  notification.Body,
-			},
-			Data: notification.Data,
-		}
-
-		if notification.Priority == "high" {
-			msg.Android = &messaging.AndroidConfig{
-				Priority: "high",
-			}
-			msg.APNS = &messaging.APNSConfig{
-				Headers: map[string]string{
-					"apns-priority": "10",
-				},
-			}
-		}
-
-		messages = append(messages, msg)
+func circuitStatus(breaker *fcm.CircuitBreaker) *model.CircuitBreakerStatus {
+	if breaker == nil {
+		return nil
 	}
 
-	batchResponse, err := s.fcmClient.SendBatchNotifications(ctx, messages)
-	if err != nil {
-		return nil, fmt.Errorf("failed to send batch: %w", err)
-	}
-	response := &model.BatchPushResponse{
-		SuccessCount: batchResponse.SuccessCount,
-		FailureCount: batchResponse.FailureCount,
-		Results:      make([]model.PushResponse, len(batchResponse.Responses)),
+	status := breaker.Status()
+	return &model.CircuitBreakerStatus{
+		State:       string(status.State),
+		Requests:    status.Requests,
+		Failures:    status.Failures,
+		FailureRate: status.FailureRate,
+		OpenedAt:    status.OpenedAt,
 	}
-
-	for i, resp := range batchResponse.Responses {
-		if resp.Success {
-			response.Results[i] = model.PushResponse{
-				Success:   true,
-				MessageID: resp.MessageID,
-			}
-		} else {
-			response.Results[i] = model.PushResponse{
-				Success: false,
-				Error:   resp.Error.Error(),
-			}
-		}
-	}
-
-	log.Printf("Batch push completed. Success: %d, Failed: %d", response.SuccessCount, response.FailureCount)
-	return response, nil
 }
 
 func maskToken(token string) string {
@@ -2,41 +2,289 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"sort"
 	"time"
 
 	"github.com/galyym/fcm_push/internal/model"
 	"github.com/galyym/fcm_push/internal/repository"
+	"github.com/galyym/fcm_push/pkg/fcm"
 	"github.com/google/uuid"
 )
 
+var (
+	// ErrInvalidRequest is returned when a request fails validation.
+	ErrInvalidRequest = errors.New("invalid request")
+	// ErrInvalidSendAt is returned when a requested send_at is outside the allowed window.
+	ErrInvalidSendAt = fmt.Errorf("%w: invalid send_at", ErrInvalidRequest)
+	// ErrTokenInvalid is returned when enqueueing to a token FCM reported as dead.
+	ErrTokenInvalid = errors.New("token is invalid")
+)
+
+type QueueConfig struct {
+	// MaxScheduleAhead is how far in the future send_at may be.
+	MaxScheduleAhead time.Duration
+	// SendAtTolerance is how far in the past send_at may be; such tasks are sent immediately.
+	SendAtTolerance time.Duration
+	// IdempotencyRetention is how long an idempotency key maps to its task.
+	IdempotencyRetention time.Duration
+	// ContentDedupWindow suppresses requests without an idempotency key that
+	// repeat the token, title and body of a recent task. Zero disables it.
+	ContentDedupWindow time.Duration
+	// ContentDedupDisabledClients lists client IDs exempt from content dedup.
+	ContentDedupDisabledClients []string
+}
+
 type QueueService struct {
-	repo *repository.QueueRepository
+	repo      *repository.QueueRepository
+	tokenRepo *repository.TokenRepository
+	breaker   *fcm.CircuitBreaker
+	config    QueueConfig
 }
 
-func NewQueueService(repo *repository.QueueRepository) *QueueService {
+func NewQueueService(repo *repository.QueueRepository, tokenRepo *repository.TokenRepository, breaker *fcm.CircuitBreaker, config QueueConfig) *QueueService {
+	if config.MaxScheduleAhead == 0 {
+		config.MaxScheduleAhead = 30 * 24 * time.Hour
+	}
+	if config.SendAtTolerance == 0 {
+		config.SendAtTolerance = 5 * time.Minute
+	}
+	if config.IdempotencyRetention == 0 {
+		config.IdempotencyRetention = 24 * time.Hour
+	}
+
 	return &QueueService{
-		repo: repo,
+		repo:      repo,
+		tokenRepo: tokenRepo,
+		breaker:   breaker,
+		config:    config,
+	}
+}
+
+func (s *QueueService) validateSendAt(sendAt *time.Time) error {
+	if sendAt == nil {
+		return nil
+	}
+
+	now := time.Now()
+	if sendAt.Before(now.Add(-s.config.SendAtTolerance)) {
+		return fmt.Errorf("%w: %s is more than %s in the past",
+			ErrInvalidSendAt, sendAt.Format(time.RFC3339), s.config.SendAtTolerance)
+	}
+	if sendAt.After(now.Add(s.config.MaxScheduleAhead)) {
+		return fmt.Errorf("%w: %s is more than %s in the future",
+			ErrInvalidSendAt, sendAt.Format(time.RFC3339), s.config.MaxScheduleAhead)
+	}
+
+	return nil
+}
+
+// resolveExpiry turns req's TTL into an absolute ExpiresAt, counted from the
+// send time, and checks that the message is still alive when it is due.
+func resolveExpiry(req *model.CreateQueueTaskRequest) error {
+	if req.TTL == nil && req.ExpiresAt == nil {
+		return nil
+	}
+	if req.TTL != nil && req.ExpiresAt != nil {
+		return fmt.Errorf("%w: ttl and expires_at are mutually exclusive", ErrInvalidRequest)
+	}
+
+	start := time.Now()
+	if req.SendAt != nil && req.SendAt.After(start) {
+		start = *req.SendAt
+	}
+
+	if req.TTL != nil {
+		if *req.TTL <= 0 {
+			return fmt.Errorf("%w: ttl must be positive", ErrInvalidRequest)
+		}
+		expiresAt := start.Add(time.Duration(*req.TTL) * time.Second)
+		req.ExpiresAt = &expiresAt
+		req.TTL = nil
+	}
+
+	if !req.ExpiresAt.After(start) {
+		return fmt.Errorf("%w: expires_at must be after the send time", ErrInvalidRequest)
+	}
+	if req.ExpiresAt.Sub(start) > fcm.MaxTTL {
+		return fmt.Errorf("%w: expires_at is more than %s after the send time", ErrInvalidRequest, fcm.MaxTTL)
+	}
+
+	return nil
+}
+
+// validateTarget checks that req has exactly one well-formed target and
+// normalizes its topic name.
+func validateTarget(req *model.CreateQueueTaskRequest) error {
+	targets := 0
+	for _, target := range []string{req.Token, req.Topic, req.Condition} {
+		if target != "" {
+			targets++
+		}
+	}
+	if targets != 1 {
+		return fmt.Errorf("%w: exactly one of token, topic or condition is required", ErrInvalidRequest)
+	}
+
+	if req.Topic != "" {
+		topic, err := normalizeTopic(req.Topic)
+		if err != nil {
+			return err
+		}
+		req.Topic = topic
+	}
+
+	if req.Condition != "" {
+		if err := fcm.ValidateCondition(req.Condition); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+		}
+	}
+
+	return nil
+}
+
+// validateContent checks the target and message of req.
+func validateContent(req *model.CreateQueueTaskRequest) error {
+	if req.MessageType == model.MessageTypeRaw {
+		if err := resolveRawTarget(req); err != nil {
+			return err
+		}
+	}
+	if err := validateTarget(req); err != nil {
+		return err
+	}
+	return validateMessage(req)
+}
+
+// resolveRawTarget validates req's raw message and takes the task's target
+// from it.
+func resolveRawTarget(req *model.CreateQueueTaskRequest) error {
+	if req.TargetType() != "" {
+		return fmt.Errorf("%w: the target of a raw message is set in the message", ErrInvalidRequest)
+	}
+
+	target, err := fcm.ValidateRawMessage(req.RawMessage)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+
+	req.Token = target.Token
+	req.Topic = target.Topic
+	req.Condition = target.Condition
+	return nil
+}
+
+// validateMessage checks that req is a notification with title and body, a
+// data-only message with data and nothing that would be displayed, or a raw
+// message with nothing besides it.
+func validateMessage(req *model.CreateQueueTaskRequest) error {
+	switch req.MessageType {
+	case "", model.MessageTypeNotification:
+		if req.Title == "" || req.Body == "" {
+			return fmt.Errorf("%w: title and body are required", ErrInvalidRequest)
+		}
+	case model.MessageTypeData:
+		if len(req.Data) == 0 {
+			return fmt.Errorf("%w: data messages need data", ErrInvalidRequest)
+		}
+		if req.Title != "" || req.Body != "" {
+			return fmt.Errorf("%w: data messages have no title or body", ErrInvalidRequest)
+		}
+		if req.Platform != nil {
+			return fmt.Errorf("%w: android, apns and webpush options are not supported for data messages", ErrInvalidRequest)
+		}
+		if req.Priority == model.PriorityHigh {
+			return fmt.Errorf("%w: data messages are always sent with normal priority", ErrInvalidRequest)
+		}
+	case model.MessageTypeRaw:
+		if len(req.RawMessage) == 0 {
+			return fmt.Errorf("%w: raw messages need a message", ErrInvalidRequest)
+		}
+		if req.Title != "" || req.Body != "" || len(req.Data) > 0 || req.Platform != nil {
+			return fmt.Errorf("%w: the content of a raw message is set in the message", ErrInvalidRequest)
+		}
+		if req.TTL != nil || req.ExpiresAt != nil || req.CollapseKey != "" {
+			return fmt.Errorf("%w: ttl, expires_at and collapse_key of a raw message are set in the message", ErrInvalidRequest)
+		}
+	default:
+		return fmt.Errorf("%w: unknown message type %q", ErrInvalidRequest, req.MessageType)
+	}
+
+	if err := req.Platform.Validate(); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+
+	return nil
+}
+
+// checkToken rejects tokens FCM has reported as dead. Topic and condition
+// targets have no token and always pass.
+func (s *QueueService) checkToken(ctx context.Context, token string) error {
+	if token == "" {
+		return nil
+	}
+
+	status, err := s.tokenRepo.IsInvalid(ctx, token)
+	if err != nil {
+		log.Printf("Failed to check token status: %v", err)
+		return nil
 	}
+	if status != nil {
+		code := model.ErrorCodeTokenInvalid
+		if status.ErrorCode != nil {
+			code = *status.ErrorCode
+		}
+		return fmt.Errorf("%w: reported %s by FCM at %s",
+			ErrTokenInvalid, code, status.LastFailedAt.Format(time.RFC3339))
+	}
+	return nil
 }
 
 func (s *QueueService) EnqueuePush(ctx context.Context, req *model.CreateQueueTaskRequest) (*model.QueueTaskResponse, error) {
 	log.Printf("Enqueueing push notification for client: %s", req.ClientID)
 
-	isDup, err := s.repo.IsDuplicateTask(ctx, req.Token, req.Title, req.Body, 10*time.Second)
-	if err != nil {
-		log.Printf("Failed to check for duplicates: %v", err)
+	if err := validateContent(req); err != nil {
+		return nil, err
+	}
+
+	if err := s.validateSendAt(req.SendAt); err != nil {
+		return nil, err
 	}
-	if isDup {
-		log.Printf("Duplicate push task suppressed for client: %s", req.ClientID)
-		return &model.QueueTaskResponse{
-			ID:        uuid.New(),
-			Status:    "duplicate_suppressed",
-			ClientID:  req.ClientID,
-			CreatedAt: time.Now(),
-			UpdatedAt: time.Now(),
-		}, nil
+
+	if err := resolveExpiry(req); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkToken(ctx, req.Token); err != nil {
+		return nil, err
+	}
+
+	if req.IdempotencyKey != "" {
+		task, created, err := s.repo.CreateTaskIdempotent(ctx, req, s.config.IdempotencyRetention)
+		if err != nil {
+			return nil, fmt.Errorf("failed to enqueue push: %w", err)
+		}
+		if !created {
+			log.Printf("Idempotency key %q already used by task %s, client: %s", req.IdempotencyKey, task.ID, req.ClientID)
+			return duplicateResponse(task), nil
+		}
+
+		log.Printf("Push notification enqueued successfully, task ID: %s", task.ID)
+		return enqueueResponse(task), nil
+	}
+
+	// Raw messages keep their content in the raw JSON, so they are not deduplicated.
+	if req.MessageType != model.MessageTypeRaw && s.contentDedupEnabled(req.ClientID) {
+		dup, err := s.repo.FindRecentDuplicate(ctx, req, s.config.ContentDedupWindow)
+		if err != nil {
+			log.Printf("Failed to check for duplicates: %v", err)
+		}
+		if dup != nil {
+			log.Printf("Duplicate push task suppressed for client: %s, existing task ID: %s", req.ClientID, dup.ID)
+			return duplicateResponse(dup), nil
+		}
 	}
 
 	task, err := s.repo.CreateTask(ctx, req)
@@ -46,15 +294,19 @@ func (s *QueueService) EnqueuePush(ctx context.Context, req *model.CreateQueueTa
 
 	log.Printf("Push notification enqueued successfully, task ID: %s", task.ID)
 
-	return &model.QueueTaskResponse{
-		ID:          task.ID,
-		Status:      task.Status,
-		ClientID:    task.ClientID,
-		Attempts:    task.Attempts,
-		MaxAttempts: task.MaxAttempts,
-		CreatedAt:   task.CreatedAt,
-		UpdatedAt:   task.UpdatedAt,
-	}, nil
+	return enqueueResponse(task), nil
+}
+
+func (s *QueueService) contentDedupEnabled(clientID string) bool {
+	if s.config.ContentDedupWindow <= 0 {
+		return false
+	}
+	for _, disabled := range s.config.ContentDedupDisabledClients {
+		if disabled == clientID {
+			return false
+		}
+	}
+	return true
 }
 
 func (s *QueueService) EnqueueBatchPush(ctx context.Context, notifications []model.CreateQueueTaskRequest) ([]model.QueueTaskResponse, error) {
@@ -63,7 +315,51 @@ func (s *QueueService) EnqueueBatchPush(ctx context.Context, notifications []mod
 	responses := make([]model.QueueTaskResponse, 0, len(notifications))
 
 	for i, req := range notifications {
-		task, err := s.repo.CreateTask(ctx, &req)
+		if err := validateContent(&req); err != nil {
+			responses = append(responses, model.QueueTaskResponse{
+				Status:       model.StatusFailed,
+				ClientID:     req.ClientID,
+				ErrorMessage: stringPtr(err.Error()),
+			})
+			continue
+		}
+
+		if err := s.validateSendAt(req.SendAt); err != nil {
+			responses = append(responses, model.QueueTaskResponse{
+				Status:       model.StatusFailed,
+				ClientID:     req.ClientID,
+				ErrorMessage: stringPtr(err.Error()),
+			})
+			continue
+		}
+
+		if err := resolveExpiry(&req); err != nil {
+			responses = append(responses, model.QueueTaskResponse{
+				Status:       model.StatusFailed,
+				ClientID:     req.ClientID,
+				ErrorMessage: stringPtr(err.Error()),
+			})
+			continue
+		}
+
+		if err := s.checkToken(ctx, req.Token); err != nil {
+			responses = append(responses, model.QueueTaskResponse{
+				Status:       model.StatusFailed,
+				ClientID:     req.ClientID,
+				ErrorMessage: stringPtr(err.Error()),
+				ErrorCode:    stringPtr(model.ErrorCodeTokenInvalid),
+			})
+			continue
+		}
+
+		var task *model.PushQueueTask
+		var err error
+		created := true
+		if req.IdempotencyKey != "" {
+			task, created, err = s.repo.CreateTaskIdempotent(ctx, &req, s.config.IdempotencyRetention)
+		} else {
+			task, err = s.repo.CreateTask(ctx, &req)
+		}
 		if err != nil {
 			log.Printf("Failed to enqueue notification %d: %v", i, err)
 			// Continue with other notifications
@@ -74,49 +370,436 @@ func (s *QueueService) EnqueueBatchPush(ctx context.Context, notifications []mod
 			continue
 		}
 
-		responses = append(responses, model.QueueTaskResponse{
-			ID:          task.ID,
-			Status:      task.Status,
-			ClientID:    task.ClientID,
-			Attempts:    task.Attempts,
-			MaxAttempts: task.MaxAttempts,
-			CreatedAt:   task.CreatedAt,
-			UpdatedAt:   task.UpdatedAt,
-		})
+		if !created {
+			responses = append(responses, *duplicateResponse(task))
+			continue
+		}
+		responses = append(responses, *enqueueResponse(task))
 	}
 
 	log.Printf("Batch push notifications enqueued, total: %d", len(responses))
 	return responses, nil
 }
 
+// EnqueueMulticast enqueues req's message once for every distinct token,
+// grouped so the outcome can be followed with GetSendGroup.
+func (s *QueueService) EnqueueMulticast(ctx context.Context, req *model.MulticastPushRequest) (*model.SendGroupResponse, error) {
+	if err := s.validateSendAt(req.SendAt); err != nil {
+		return nil, err
+	}
+
+	template := &model.CreateQueueTaskRequest{
+		MessageType:    req.Type,
+		Title:          req.Title,
+		Body:           req.Body,
+		Data:           req.Data,
+		Platform:       model.NewPlatformOptions(req.Android, req.APNS, req.Webpush),
+		Priority:       req.Priority,
+		TTL:            req.TTL,
+		ExpiresAt:      req.ExpiresAt,
+		CollapseKey:    req.CollapseKey,
+		ClientID:       req.ClientID,
+		SendAt:         req.SendAt,
+		IdempotencyKey: req.IdempotencyKey,
+		RetryPolicy:    req.RetryPolicy,
+	}
+
+	if err := validateMessage(template); err != nil {
+		return nil, err
+	}
+
+	if err := resolveExpiry(template); err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]struct{}, len(req.Tokens))
+	unique := make([]string, 0, len(req.Tokens))
+	for _, token := range req.Tokens {
+		if _, dup := seen[token]; dup {
+			continue
+		}
+		seen[token] = struct{}{}
+		unique = append(unique, token)
+	}
+
+	tokens, invalidTokens := s.dropInvalidTokens(ctx, unique)
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("%w: all %d tokens were reported dead by FCM", ErrTokenInvalid, len(invalidTokens))
+	}
+
+	group, created, err := s.repo.CreateSendGroup(ctx, template, tokens, s.config.IdempotencyRetention)
+	if err != nil {
+		return nil, fmt.Errorf("failed to enqueue multicast: %w", err)
+	}
+
+	if !created {
+		log.Printf("Idempotency key %q already used by send group %s, client: %s", req.IdempotencyKey, group.ID, req.ClientID)
+		response, err := s.repo.GetSendGroup(ctx, group.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get send group: %w", err)
+		}
+		response.Duplicate = true
+		return response, nil
+	}
+
+	log.Printf("Multicast enqueued, group ID: %s, tokens: %d (%d duplicates and %d invalid dropped)",
+		group.ID, len(tokens), len(req.Tokens)-len(unique), len(invalidTokens))
+
+	return &model.SendGroupResponse{
+		GroupID:         group.ID,
+		ClientID:        group.ClientID,
+		Title:           group.Title,
+		Body:            group.Body,
+		TokenCount:      group.TokenCount,
+		PendingCount:    group.TokenCount,
+		DuplicateTokens: len(req.Tokens) - len(unique),
+		InvalidTokens:   invalidTokens,
+		CreatedAt:       group.CreatedAt,
+	}, nil
+}
+
+// dropInvalidTokens splits tokens into those to send to and those FCM has
+// reported as dead, looking them all up at once. If the lookup fails every
+// token is kept, as in checkToken.
+func (s *QueueService) dropInvalidTokens(ctx context.Context, tokens []string) ([]string, []string) {
+	invalid, err := s.tokenRepo.FindInvalid(ctx, tokens)
+	if err != nil {
+		log.Printf("Failed to check token status: %v", err)
+		return tokens, nil
+	}
+	if len(invalid) == 0 {
+		return tokens, nil
+	}
+
+	live := make([]string, 0, len(tokens)-len(invalid))
+	var dropped []string
+	for _, token := range tokens {
+		if _, dead := invalid[token]; dead {
+			dropped = append(dropped, token)
+			continue
+		}
+		live = append(live, token)
+	}
+	return live, dropped
+}
+
+// GetSendGroup reports how the tasks of a multicast group are progressing.
+func (s *QueueService) GetSendGroup(ctx context.Context, groupID uuid.UUID) (*model.SendGroupResponse, error) {
+	group, err := s.repo.GetSendGroup(ctx, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get send group: %w", err)
+	}
+
+	return group, nil
+}
+
 func (s *QueueService) GetTaskStatus(ctx context.Context, taskID uuid.UUID) (*model.QueueTaskResponse, error) {
 	task, err := s.repo.GetTaskByID(ctx, taskID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get task status: %w", err)
 	}
 
+	return taskResponse(task), nil
+}
+
+// CancelTask cancels a task that has not been claimed by a worker yet.
+func (s *QueueService) CancelTask(ctx context.Context, taskID uuid.UUID) (*model.QueueTaskResponse, error) {
+	task, err := s.repo.CancelTask(ctx, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel task: %w", err)
+	}
+
+	log.Printf("Task %s cancelled", task.ID)
+	return taskResponse(task), nil
+}
+
+// UpdateTask changes the send time, priority or payload of a pending task.
+// The payload of a raw message cannot be changed.
+func (s *QueueService) UpdateTask(ctx context.Context, taskID uuid.UUID, req *model.UpdateQueueTaskRequest) (*model.QueueTaskResponse, error) {
+	if err := s.validateSendAt(req.SendAt); err != nil {
+		return nil, err
+	}
+
+	task, err := s.repo.GetTaskByID(ctx, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update task: %w", err)
+	}
+
+	if req.SendAt != nil && task.ExpiresAt != nil && !req.SendAt.Before(*task.ExpiresAt) {
+		return nil, fmt.Errorf("%w: send_at must be before the task's expires_at", ErrInvalidRequest)
+	}
+
+	if task.MessageType == model.MessageTypeRaw {
+		if req.Title != nil || req.Body != nil || req.Data != nil {
+			return nil, fmt.Errorf("%w: only send_at and priority of a raw message can be changed", ErrInvalidRequest)
+		}
+	} else if err := validateContent(mergedContent(task, req)); err != nil {
+		return nil, err
+	}
+
+	task, err = s.repo.UpdatePendingTask(ctx, taskID, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update task: %w", err)
+	}
+
+	log.Printf("Task %s updated, scheduled at %s", task.ID, task.ScheduledAt.Format(time.RFC3339))
+	return taskResponse(task), nil
+}
+
+// mergedContent returns the content task would have after the edits in req,
+// so it can be checked like a new task.
+func mergedContent(task *model.PushQueueTask, req *model.UpdateQueueTaskRequest) *model.CreateQueueTaskRequest {
+	merged := &model.CreateQueueTaskRequest{
+		Token:       task.Token,
+		Topic:       task.Topic,
+		Condition:   task.Condition,
+		MessageType: task.MessageType,
+		Title:       task.Title,
+		Body:        task.Body,
+		Data:        task.Data,
+		Platform:    task.Platform,
+		Priority:    task.Priority,
+	}
+	if req.Title != nil {
+		merged.Title = *req.Title
+	}
+	if req.Body != nil {
+		merged.Body = *req.Body
+	}
+	if req.Data != nil {
+		merged.Data = req.Data
+	}
+	if req.Priority != nil {
+		merged.Priority = *req.Priority
+	}
+	return merged
+}
+
+// RetryTask resets a failed task so it is sent again right away.
+func (s *QueueService) RetryTask(ctx context.Context, taskID uuid.UUID) (*model.QueueTaskResponse, error) {
+	task, err := s.repo.RetryTask(ctx, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retry task: %w", err)
+	}
+
+	log.Printf("Task %s requeued for retry", task.ID)
+	return taskResponse(task), nil
+}
+
+// RetryFailedTasks requeues every failed task matching req.
+func (s *QueueService) RetryFailedTasks(ctx context.Context, req *model.BulkRetryRequest) (*model.BulkRetryResponse, error) {
+	if req.EndDate.Before(*req.StartDate) {
+		return nil, fmt.Errorf("%w: end_date is before start_date", ErrInvalidRequest)
+	}
+
+	count, err := s.repo.RetryFailedTasks(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retry failed tasks: %w", err)
+	}
+
+	response := &model.BulkRetryResponse{
+		MatchedCount: count,
+		DryRun:       req.DryRun,
+	}
+	if !req.DryRun {
+		response.RetriedCount = count
+		log.Printf("Bulk retry requeued %d failed tasks (client: %q, %s - %s)",
+			count, req.ClientID, req.StartDate.Format(time.RFC3339), req.EndDate.Format(time.RFC3339))
+	}
+
+	return response, nil
+}
+
+// GetTaskTimeline reconstructs the state changes of a task from its row, its
+// attempt log and its dead-letter entry. It still works after the task has
+// been cleaned up from the queue, as long as its attempts are retained.
+func (s *QueueService) GetTaskTimeline(ctx context.Context, taskID uuid.UUID) (*model.TaskTimelineResponse, error) {
+	task, err := s.repo.GetTaskByID(ctx, taskID)
+	if err != nil && !errors.Is(err, repository.ErrTaskNotFound) {
+		return nil, fmt.Errorf("failed to get task: %w", err)
+	}
+
+	entry, err := s.repo.GetDeadLetter(ctx, taskID)
+	if err != nil && !errors.Is(err, repository.ErrDeadLetterNotFound) {
+		return nil, fmt.Errorf("failed to get dead letter: %w", err)
+	}
+
+	attempts, err := s.repo.GetAttempts(ctx, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get attempts: %w", err)
+	}
+
+	if task == nil && entry == nil && len(attempts) == 0 {
+		return nil, repository.ErrTaskNotFound
+	}
+
+	timeline := &model.TaskTimelineResponse{
+		TaskID: taskID,
+		Status: model.StatusFailed,
+		Events: []model.TaskEvent{},
+	}
+
+	maxAttempts := 0
+	switch {
+	case task != nil:
+		timeline.Status = task.Status
+		maxAttempts = task.MaxAttempts
+		timeline.Events = append(timeline.Events, model.TaskEvent{
+			At:     task.CreatedAt,
+			Event:  "created",
+			Status: model.StatusPending,
+		})
+	case entry != nil:
+		maxAttempts = entry.MaxAttempts
+		timeline.Events = append(timeline.Events, model.TaskEvent{
+			At:     entry.TaskCreatedAt,
+			Event:  "created",
+			Status: model.StatusPending,
+		})
+	}
+
+	for _, attempt := range attempts {
+		timeline.Events = append(timeline.Events, model.TaskEvent{
+			At:       attempt.StartedAt,
+			Event:    "claimed",
+			Status:   model.StatusProcessing,
+			Attempt:  attempt.Attempt,
+			WorkerID: attempt.WorkerID,
+		})
+
+		latency := attempt.LatencyMs
+		timeline.Events = append(timeline.Events, model.TaskEvent{
+			At:           attempt.FinishedAt,
+			Event:        attempt.Outcome,
+			Status:       attemptStatus(attempt, maxAttempts),
+			Attempt:      attempt.Attempt,
+			WorkerID:     attempt.WorkerID,
+			LatencyMs:    &latency,
+			ErrorCode:    attempt.ErrorCode,
+			ErrorMessage: attempt.ErrorMessage,
+			FCMMessageID: attempt.FCMMessageID,
+		})
+	}
+
+	if entry != nil {
+		timeline.Events = append(timeline.Events, model.TaskEvent{
+			At:           entry.DeadLetteredAt,
+			Event:        "dead_lettered",
+			Status:       model.StatusFailed,
+			ErrorCode:    entry.ErrorCode,
+			ErrorMessage: entry.ErrorMessage,
+		})
+	}
+
+	if task != nil && closedWithoutSend(task, attempts) {
+		timeline.Events = append(timeline.Events, model.TaskEvent{
+			At:           task.UpdatedAt,
+			Event:        string(task.Status),
+			Status:       task.Status,
+			ErrorCode:    task.ErrorCode,
+			ErrorMessage: task.ErrorMessage,
+		})
+	}
+
+	sort.SliceStable(timeline.Events, func(i, j int) bool {
+		return timeline.Events[i].At.Before(timeline.Events[j].At)
+	})
+
+	return timeline, nil
+}
+
+// closedWithoutSend reports whether task was closed by something other than
+// one of its attempts, and so needs an event of its own.
+func closedWithoutSend(task *model.PushQueueTask, attempts []model.PushAttempt) bool {
+	switch task.Status {
+	case model.StatusCancelled, model.StatusSkipped, model.StatusSuperseded:
+		return true
+	case model.StatusExpired:
+		// A task that expired while waiting for a retry already has an expired attempt.
+		return len(attempts) == 0 || attempts[len(attempts)-1].Outcome != model.AttemptExpired
+	default:
+		return false
+	}
+}
+
+// attemptStatus is the task status an attempt left behind.
+func attemptStatus(attempt model.PushAttempt, maxAttempts int) model.QueueStatus {
+	switch attempt.Outcome {
+	case model.AttemptSuccess:
+		return model.StatusSuccess
+	case model.AttemptFailed:
+		return model.StatusFailed
+	case model.AttemptExpired:
+		return model.StatusExpired
+	case model.AttemptLeaseExpired:
+		if maxAttempts > 0 && attempt.Attempt >= maxAttempts {
+			return model.StatusFailed
+		}
+		return model.StatusPending
+	default:
+		return model.StatusPending
+	}
+}
+
+func (s *QueueService) GetHistory(ctx context.Context, req *model.QueueHistoryRequest) (*model.QueueHistoryResponse, error) {
+	return s.repo.GetHistory(ctx, req)
+}
+
+func (s *QueueService) GetStats(ctx context.Context) (*model.QueueStatsResponse, error) {
+	stats, err := s.repo.GetStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stats.FCMCircuit = circuitStatus(s.breaker)
+	return stats, nil
+}
+
+func enqueueResponse(task *model.PushQueueTask) *model.QueueTaskResponse {
+	return &model.QueueTaskResponse{
+		ID:          task.ID,
+		Status:      task.Status,
+		ClientID:    task.ClientID,
+		Attempts:    task.Attempts,
+		MaxAttempts: task.MaxAttempts,
+		SendAt:      task.SendAt,
+		ScheduledAt: task.ScheduledAt,
+		CreatedAt:   task.CreatedAt,
+		UpdatedAt:   task.UpdatedAt,
+	}
+}
+
+func duplicateResponse(task *model.PushQueueTask) *model.QueueTaskResponse {
+	response := enqueueResponse(task)
+	response.Duplicate = true
+	return response
+}
+
+func taskResponse(task *model.PushQueueTask) *model.QueueTaskResponse {
 	return &model.QueueTaskResponse{
 		ID:           task.ID,
 		Status:       task.Status,
+		TargetType:   task.TargetType,
 		Token:        task.Token,
+		Topic:        task.Topic,
+		Condition:    task.Condition,
+		GroupID:      task.GroupID,
+		MessageType:  task.MessageType,
 		Title:        task.Title,
 		Body:         task.Body,
+		ExpiresAt:    task.ExpiresAt,
+		CollapseKey:  task.CollapseKey,
 		ClientID:     task.ClientID,
 		Attempts:     task.Attempts,
 		MaxAttempts:  task.MaxAttempts,
 		ErrorMessage: task.ErrorMessage,
+		ErrorCode:    task.ErrorCode,
 		FCMMessageID: task.FCMMessageID,
+		SendAt:       task.SendAt,
+		ScheduledAt:  task.ScheduledAt,
 		CreatedAt:    task.CreatedAt,
 		UpdatedAt:    task.UpdatedAt,
-	}, nil
-}
-
-func (s *QueueService) GetHistory(ctx context.Context, req *model.QueueHistoryRequest) (*model.QueueHistoryResponse, error) {
-	return s.repo.GetHistory(ctx, req)
-}
-
-func (s *QueueService) GetStats(ctx context.Context) (*model.QueueStatsResponse, error) {
-	return s.repo.GetStats(ctx)
+	}
 }
 
 func stringPtr(s string) *string {
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/galyym/fcm_push/internal/model"
+	"github.com/galyym/fcm_push/pkg/fcm"
+)
+
+func TestValidateTarget(t *testing.T) {
+	tests := []struct {
+		name      string
+		req       model.CreateQueueTaskRequest
+		wantTopic string
+		wantErr   bool
+	}{
+		{name: "token", req: model.CreateQueueTaskRequest{Token: "device_token"}},
+		{name: "topic", req: model.CreateQueueTaskRequest{Topic: "news"}, wantTopic: "news"},
+		{name: "topic prefix is stripped", req: model.CreateQueueTaskRequest{Topic: "/topics/news"}, wantTopic: "news"},
+		{name: "condition", req: model.CreateQueueTaskRequest{Condition: "'news' in topics || 'sport' in topics"}},
+		{name: "no target", req: model.CreateQueueTaskRequest{}, wantErr: true},
+		{name: "token and topic", req: model.CreateQueueTaskRequest{Token: "device_token", Topic: "news"}, wantErr: true},
+		{name: "topic and condition", req: model.CreateQueueTaskRequest{Topic: "news", Condition: "'news' in topics"}, wantErr: true},
+		{name: "invalid topic", req: model.CreateQueueTaskRequest{Topic: "news!"}, wantErr: true},
+		{name: "invalid condition", req: model.CreateQueueTaskRequest{Condition: "news in topics"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.req
+			err := validateTarget(&req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateTarget() = %v, want error: %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidRequest) {
+				t.Errorf("validateTarget() = %v, want %v", err, ErrInvalidRequest)
+			}
+			if err == nil && req.Topic != tt.wantTopic {
+				t.Errorf("topic = %q, want %q", req.Topic, tt.wantTopic)
+			}
+		})
+	}
+}
+
+func TestValidateMessage(t *testing.T) {
+	data := map[string]string{"action": "sync"}
+	android := &model.PlatformOptions{Android: &fcm.AndroidOptions{ChannelID: "orders"}}
+
+	tests := []struct {
+		name    string
+		req     model.CreateQueueTaskRequest
+		wantErr bool
+	}{
+		{name: "notification", req: model.CreateQueueTaskRequest{Title: "title", Body: "body"}},
+		{name: "explicit notification type", req: model.CreateQueueTaskRequest{MessageType: model.MessageTypeNotification, Title: "title", Body: "body", Data: data}},
+		{name: "notification without title", req: model.CreateQueueTaskRequest{Body: "body"}, wantErr: true},
+		{name: "notification without body", req: model.CreateQueueTaskRequest{Title: "title"}, wantErr: true},
+		{name: "notification with invalid options", req: model.CreateQueueTaskRequest{
+			Title: "title", Body: "body", Platform: &model.PlatformOptions{Android: &fcm.AndroidOptions{Color: "red"}},
+		}, wantErr: true},
+		{name: "data-only", req: model.CreateQueueTaskRequest{MessageType: model.MessageTypeData, Data: data}},
+		{name: "data-only without data", req: model.CreateQueueTaskRequest{MessageType: model.MessageTypeData}, wantErr: true},
+		{name: "data-only with title", req: model.CreateQueueTaskRequest{MessageType: model.MessageTypeData, Title: "title", Data: data}, wantErr: true},
+		{name: "data-only with body", req: model.CreateQueueTaskRequest{MessageType: model.MessageTypeData, Body: "body", Data: data}, wantErr: true},
+		{name: "data-only with platform options", req: model.CreateQueueTaskRequest{MessageType: model.MessageTypeData, Data: data, Platform: android}, wantErr: true},
+		{name: "data-only with high priority", req: model.CreateQueueTaskRequest{MessageType: model.MessageTypeData, Data: data, Priority: model.PriorityHigh}, wantErr: true},
+		{name: "unknown type", req: model.CreateQueueTaskRequest{MessageType: "silent", Data: data}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateMessage(&tt.req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateMessage() = %v, want error: %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidRequest) {
+				t.Errorf("validateMessage() = %v, want %v", err, ErrInvalidRequest)
+			}
+		})
+	}
+}
+
+func TestResolveExpiry(t *testing.T) {
+	now := time.Now()
+	sendAt := now.Add(time.Hour)
+
+	tests := []struct {
+		name          string
+		req           model.CreateQueueTaskRequest
+		wantExpiresAt time.Time
+		wantErr       bool
+	}{
+		{name: "no expiry"},
+		{name: "ttl from send_at", req: model.CreateQueueTaskRequest{TTL: intPtr(60), SendAt: &sendAt}, wantExpiresAt: sendAt.Add(time.Minute)},
+		{name: "expires_at", req: model.CreateQueueTaskRequest{ExpiresAt: timePtr(now.Add(time.Hour))}, wantExpiresAt: now.Add(time.Hour)},
+		{name: "ttl and expires_at", req: model.CreateQueueTaskRequest{TTL: intPtr(60), ExpiresAt: timePtr(now.Add(time.Hour))}, wantErr: true},
+		{name: "zero ttl", req: model.CreateQueueTaskRequest{TTL: intPtr(0)}, wantErr: true},
+		{name: "ttl beyond the maximum", req: model.CreateQueueTaskRequest{TTL: intPtr(int(fcm.MaxTTL/time.Second) + 1)}, wantErr: true},
+		{name: "expires_at in the past", req: model.CreateQueueTaskRequest{ExpiresAt: timePtr(now.Add(-time.Minute))}, wantErr: true},
+		{name: "expires_at before send_at", req: model.CreateQueueTaskRequest{ExpiresAt: timePtr(now.Add(time.Minute)), SendAt: &sendAt}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := tt.req
+			err := resolveExpiry(&req)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveExpiry() = %v, want error: %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if !errors.Is(err, ErrInvalidRequest) {
+					t.Errorf("resolveExpiry() = %v, want %v", err, ErrInvalidRequest)
+				}
+				return
+			}
+			if req.TTL != nil {
+				t.Errorf("ttl = %d, want it resolved into expires_at", *req.TTL)
+			}
+			if tt.wantExpiresAt.IsZero() {
+				if req.ExpiresAt != nil {
+					t.Errorf("expires_at = %v, want nil", req.ExpiresAt)
+				}
+				return
+			}
+			if req.ExpiresAt == nil || !req.ExpiresAt.Equal(tt.wantExpiresAt) {
+				t.Errorf("expires_at = %v, want %v", req.ExpiresAt, tt.wantExpiresAt)
+			}
+		})
+	}
+}
+
+func TestValidateSendAt(t *testing.T) {
+	s := NewQueueService(nil, nil, nil, QueueConfig{
+		MaxScheduleAhead: 24 * time.Hour,
+		SendAtTolerance:  5 * time.Minute,
+	})
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		sendAt  *time.Time
+		wantErr bool
+	}{
+		{name: "not set", sendAt: nil},
+		{name: "future", sendAt: timePtr(now.Add(time.Hour))},
+		{name: "slightly past", sendAt: timePtr(now.Add(-time.Minute))},
+		{name: "past beyond tolerance", sendAt: timePtr(now.Add(-time.Hour)), wantErr: true},
+		{name: "too far ahead", sendAt: timePtr(now.Add(48 * time.Hour)), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := s.validateSendAt(tt.sendAt)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateSendAt() = %v, want error: %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidSendAt) {
+				t.Errorf("validateSendAt() = %v, want %v", err, ErrInvalidSendAt)
+			}
+		})
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+func intPtr(v int) *int {
+	return &v
+}
@@ -0,0 +1,22 @@
+package service
+
+import (
+	"context"
+
+	"github.com/galyym/fcm_push/internal/model"
+	"github.com/galyym/fcm_push/internal/repository"
+)
+
+type TokenService struct {
+	repo *repository.TokenRepository
+}
+
+func NewTokenService(repo *repository.TokenRepository) *TokenService {
+	return &TokenService{repo: repo}
+}
+
+// ListInvalid returns tokens FCM reported as dead since req.Since, so callers
+// can remove them from their own storage.
+func (s *TokenService) ListInvalid(ctx context.Context, req *model.InvalidTokensRequest) (*model.InvalidTokensResponse, error) {
+	return s.repo.ListInvalid(ctx, req)
+}
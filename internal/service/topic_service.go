@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"firebase.google.com/go/v4/messaging"
+	"github.com/galyym/fcm_push/internal/model"
+	"github.com/galyym/fcm_push/internal/repository"
+	"github.com/galyym/fcm_push/pkg/fcm"
+)
+
+type TopicService struct {
+	fcmClient *fcm.Client
+	repo      *repository.TopicRepository
+}
+
+func NewTopicService(fcmClient *fcm.Client, repo *repository.TopicRepository) *TopicService {
+	return &TopicService{
+		fcmClient: fcmClient,
+		repo:      repo,
+	}
+}
+
+// Subscribe subscribes tokens to topic in FCM and records the subscriptions
+// that succeeded. Tokens FCM rejected are reported back individually.
+func (s *TopicService) Subscribe(ctx context.Context, topic string, tokens []string) (*model.TopicManagementResponse, error) {
+	topic, err := normalizeTopic(topic)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.fcmClient.SubscribeToTopic(ctx, tokens, topic)
+	if err != nil {
+		return nil, err
+	}
+
+	result, succeeded := topicResult(topic, tokens, response)
+	if err := s.repo.AddSubscriptions(ctx, topic, succeeded); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Subscribed %d tokens to topic %s (%d failed)", result.SuccessCount, topic, result.FailureCount)
+	return result, nil
+}
+
+// Unsubscribe unsubscribes tokens from topic in FCM and forgets the
+// subscriptions that were removed.
+func (s *TopicService) Unsubscribe(ctx context.Context, topic string, tokens []string) (*model.TopicManagementResponse, error) {
+	topic, err := normalizeTopic(topic)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := s.fcmClient.UnsubscribeFromTopic(ctx, tokens, topic)
+	if err != nil {
+		return nil, err
+	}
+
+	result, succeeded := topicResult(topic, tokens, response)
+	if err := s.repo.RemoveSubscriptions(ctx, topic, succeeded); err != nil {
+		return nil, err
+	}
+
+	log.Printf("Unsubscribed %d tokens from topic %s (%d failed)", result.SuccessCount, topic, result.FailureCount)
+	return result, nil
+}
+
+// TopicsForToken lists the topics token was subscribed to through this
+// service. FCM offers no way to list them, so subscriptions made elsewhere are
+// not included.
+func (s *TopicService) TopicsForToken(ctx context.Context, token string) (*model.TokenTopicsResponse, error) {
+	topics, err := s.repo.TopicsForToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.TokenTopicsResponse{
+		Token:  token,
+		Topics: topics,
+	}, nil
+}
+
+func normalizeTopic(topic string) (string, error) {
+	name, err := fcm.NormalizeTopic(topic)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	return name, nil
+}
+
+// topicResult converts an FCM topic management response into the API model
+// and returns the tokens that were processed successfully.
+func topicResult(topic string, tokens []string, response *messaging.TopicManagementResponse) (*model.TopicManagementResponse, []string) {
+	result := &model.TopicManagementResponse{
+		Topic:        topic,
+		SuccessCount: response.SuccessCount,
+		FailureCount: response.FailureCount,
+	}
+
+	failed := make(map[int]bool, len(response.Errors))
+	for _, e := range response.Errors {
+		failed[e.Index] = true
+		failure := model.TopicTokenFailure{Index: e.Index, Reason: e.Reason}
+		if e.Index >= 0 && e.Index < len(tokens) {
+			failure.Token = tokens[e.Index]
+		}
+		result.Failures = append(result.Failures, failure)
+	}
+
+	succeeded := make([]string, 0, len(tokens)-len(failed))
+	for i, token := range tokens {
+		if !failed[i] {
+			succeeded = append(succeeded, token)
+		}
+	}
+
+	return result, succeeded
+}
@@ -0,0 +1,108 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/galyym/fcm_push/pkg/fcm"
+)
+
+// aimdLimiter bounds how many FCM requests one worker has in flight. The
+// limit grows by one per window of healthy requests (additive increase) and
+// halves when FCM is slow or returns transient errors (multiplicative
+// decrease), so each worker converges on the concurrency FCM can currently
+// absorb.
+type aimdLimiter struct {
+	mu            sync.Mutex
+	limit         float64
+	minLimit      float64
+	maxLimit      float64
+	latencyTarget time.Duration
+	inFlight      int
+	lastDecrease  time.Time
+	changed       chan struct{}
+}
+
+func newAIMDLimiter(minLimit, maxLimit int, latencyTarget time.Duration) *aimdLimiter {
+	initial := min(max(minLimit, 10), maxLimit)
+
+	return &aimdLimiter{
+		limit:         float64(initial),
+		minLimit:      float64(minLimit),
+		maxLimit:      float64(maxLimit),
+		latencyTarget: latencyTarget,
+		changed:       make(chan struct{}),
+	}
+}
+
+// Acquire blocks until a send slot is free or ctx is done.
+func (l *aimdLimiter) Acquire(ctx context.Context) error {
+	for {
+		l.mu.Lock()
+		if l.inFlight < int(l.limit) {
+			l.inFlight++
+			l.mu.Unlock()
+			return nil
+		}
+		changed := l.changed
+		l.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// Release frees a slot and adjusts the limit from the outcome of a send that
+// started at start.
+func (l *aimdLimiter) Release(start time.Time, err error) {
+	latency := time.Since(start)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inFlight--
+
+	if isCongestionSignal(err) || latency > l.latencyTarget {
+		// Only sends started after the last cut reflect the current limit;
+		// reacting to older ones would collapse the limit on a single burst.
+		if start.After(l.lastDecrease) {
+			l.limit = max(l.limit/2, l.minLimit)
+			l.lastDecrease = time.Now()
+		}
+	} else {
+		l.limit = min(l.limit+1/l.limit, l.maxLimit)
+	}
+
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// Cancel frees a slot that was acquired but never used for a send.
+func (l *aimdLimiter) Cancel() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inFlight--
+	close(l.changed)
+	l.changed = make(chan struct{})
+}
+
+// Limit returns the current concurrency limit.
+func (l *aimdLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
+
+// isCongestionSignal reports whether err suggests FCM is overloaded, as
+// opposed to a problem with the message itself.
+func isCongestionSignal(err error) bool {
+	if err == nil {
+		return false
+	}
+	return !fcm.IsPermanent(err)
+}
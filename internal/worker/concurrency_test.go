@@ -0,0 +1,113 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/galyym/fcm_push/pkg/fcm"
+)
+
+func TestNewAIMDLimiter(t *testing.T) {
+	tests := []struct {
+		name     string
+		minLimit int
+		maxLimit int
+		want     int
+	}{
+		{name: "starts at ten", minLimit: 1, maxLimit: 50, want: 10},
+		{name: "starts at the minimum above ten", minLimit: 20, maxLimit: 50, want: 20},
+		{name: "starts at the maximum below ten", minLimit: 1, maxLimit: 4, want: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newAIMDLimiter(tt.minLimit, tt.maxLimit, time.Second)
+			if got := l.Limit(); got != tt.want {
+				t.Errorf("Limit() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAIMDLimiterRelease(t *testing.T) {
+	outage := &fcm.Error{Code: fcm.ErrorCodeUnavailable, Err: errors.New("unavailable")}
+	unknown := &fcm.Error{Code: fcm.ErrorCodeUnknown, Err: errors.New("connection reset")}
+	invalid := &fcm.Error{Code: fcm.ErrorCodeInvalidArgument, Err: errors.New("invalid argument")}
+
+	tests := []struct {
+		name         string
+		limit        float64
+		minLimit     float64
+		maxLimit     float64
+		latency      time.Duration
+		err          error
+		decreasedAgo time.Duration
+		want         float64
+	}{
+		{name: "healthy request grows the limit", limit: 4, minLimit: 1, maxLimit: 50, want: 4.25},
+		{name: "growth stops at the maximum", limit: 50, minLimit: 1, maxLimit: 50, want: 50},
+		{name: "outage error halves the limit", limit: 8, minLimit: 1, maxLimit: 50, err: outage, want: 4},
+		{name: "transient error halves the limit", limit: 8, minLimit: 1, maxLimit: 50, err: unknown, want: 4},
+		{name: "slow request halves the limit", limit: 8, minLimit: 1, maxLimit: 50, latency: 2 * time.Second, want: 4},
+		{name: "permanent error is not congestion", limit: 4, minLimit: 1, maxLimit: 50, err: invalid, want: 4.25},
+		{name: "halving stops at the minimum", limit: 3, minLimit: 2, maxLimit: 50, err: outage, want: 2},
+		{
+			name:  "request started before the last cut does not cut again",
+			limit: 8, minLimit: 1, maxLimit: 50, latency: 10 * time.Millisecond, err: outage,
+			decreasedAgo: time.Millisecond, want: 8,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newAIMDLimiter(int(tt.minLimit), int(tt.maxLimit), time.Second)
+			l.limit = tt.limit
+			l.inFlight = 1
+
+			now := time.Now()
+			if tt.decreasedAgo > 0 {
+				l.lastDecrease = now.Add(-tt.decreasedAgo)
+			}
+
+			l.Release(now.Add(-tt.latency), tt.err)
+
+			if l.limit != tt.want {
+				t.Errorf("limit = %v, want %v", l.limit, tt.want)
+			}
+			if l.inFlight != 0 {
+				t.Errorf("inFlight = %d, want 0", l.inFlight)
+			}
+		})
+	}
+}
+
+func TestAIMDLimiterAcquire(t *testing.T) {
+	l := newAIMDLimiter(1, 1, time.Second)
+
+	if err := l.Acquire(context.Background()); err != nil {
+		t.Fatalf("Acquire() = %v, want nil", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := l.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Acquire() at the limit = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	acquired := make(chan error)
+	go func() {
+		acquired <- l.Acquire(context.Background())
+	}()
+
+	l.Cancel()
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Fatalf("Acquire() after Cancel = %v, want nil", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Acquire() still blocked after Cancel freed a slot")
+	}
+}
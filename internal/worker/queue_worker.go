@@ -2,34 +2,98 @@ package worker
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"log"
+	"os"
 	"sync"
 	"time"
 
+	"firebase.google.com/go/v4/messaging"
 	"github.com/galyym/fcm_push/internal/model"
 	"github.com/galyym/fcm_push/internal/repository"
 	"github.com/galyym/fcm_push/pkg/fcm"
+	"github.com/google/uuid"
+	"golang.org/x/sync/semaphore"
+)
+
+const (
+	listenerReconnectDelay = 5 * time.Second
+	// resultRecordTimeout bounds writing a batch's results, which happens on
+	// its own context so results are kept even when sends were cut short.
+	resultRecordTimeout = 30 * time.Second
 )
 
 type Config struct {
-	WorkerCount    int
-	PollInterval   time.Duration
+	WorkerCount int
+	// HighPriorityWorkers is how many of WorkerCount goroutines only claim
+	// high priority tasks, so bulk traffic can never starve them.
+	HighPriorityWorkers int
+	// PollInterval is a safety net: workers are normally woken through
+	// Postgres LISTEN/NOTIFY as soon as a task is enqueued.
+	PollInterval time.Duration
+	// RetryIntervals drive the "fixed" retry policy.
 	RetryIntervals []time.Duration
-	CleanupAfter   time.Duration
+	// BackoffBase and BackoffMax drive the "exponential" retry policy.
+	BackoffBase time.Duration
+	BackoffMax  time.Duration
+	// DefaultRetryPolicy names the policy used when neither the task nor its
+	// client selects one. ClientRetryPolicies maps client IDs to policy names.
+	DefaultRetryPolicy  string
+	ClientRetryPolicies map[string]string
+	CleanupAfter        time.Duration
+	// DeadLetterRetention is how long dead-lettered tasks are kept. It is
+	// independent of CleanupAfter so failures outlive ordinary history.
+	DeadLetterRetention time.Duration
+	// LeaseDuration is how long a claimed task stays reserved for a worker.
+	// It must comfortably exceed the time needed to process one batch.
+	LeaseDuration time.Duration
+	// ReaperInterval is how often expired leases are returned to the queue.
+	ReaperInterval time.Duration
+	// BatchSize is how many tasks a worker claims at once.
+	BatchSize int
+	// MinConcurrency and MaxConcurrency bound how many FCM requests, SendEach
+	// chunks or single raw sends, one worker keeps in flight; the actual limit
+	// adapts to FCM latency and errors. A batch is split into as many chunks
+	// as the current limit allows.
+	MinConcurrency int
+	MaxConcurrency int
+	// LatencyTarget is the request latency above which a worker backs off.
+	LatencyTarget time.Duration
+	// MaxInFlight caps how many messages are being sent to FCM at once
+	// across all workers.
+	MaxInFlight int
+}
+
+// fcmSender is the part of *fcm.Client the worker sends through.
+type fcmSender interface {
+	Breaker() *fcm.CircuitBreaker
+	SendEach(ctx context.Context, messages []*messaging.Message) []fcm.SendResult
+	SendRaw(ctx context.Context, message json.RawMessage) (string, error)
 }
 
 type QueueWorker struct {
 	repo          *repository.QueueRepository
-	fcmClient     *fcm.Client
+	tokenRepo     *repository.TokenRepository
+	fcmClient     fcmSender
 	config        Config
+	instanceID    string
+	wake          chan struct{}
+	limiters      []*aimdLimiter
+	retryPolicies map[string]RetryPolicy
+	inFlight      *semaphore.Weighted
+	// ctx stops claiming and background loops; sendCtx aborts in-flight sends.
 	ctx           context.Context
 	cancel        context.CancelFunc
+	sendCtx       context.Context
+	cancelSends   context.CancelFunc
 	wg            sync.WaitGroup
 	cleanupTicker *time.Ticker
 }
 
-func NewQueueWorker(repo *repository.QueueRepository, fcmClient *fcm.Client, config Config) *QueueWorker {
+func NewQueueWorker(repo *repository.QueueRepository, tokenRepo *repository.TokenRepository, fcmClient *fcm.Client, config Config) *QueueWorker {
 	ctx, cancel := context.WithCancel(context.Background())
+	sendCtx, cancelSends := context.WithCancel(context.Background())
 
 	if len(config.RetryIntervals) == 0 {
 		config.RetryIntervals = []time.Duration{
@@ -43,38 +107,173 @@ func NewQueueWorker(repo *repository.QueueRepository, fcmClient *fcm.Client, con
 		config.CleanupAfter = 30 * 24 * time.Hour
 	}
 
+	if config.DeadLetterRetention == 0 {
+		config.DeadLetterRetention = 90 * 24 * time.Hour
+	}
+
+	if config.HighPriorityWorkers >= config.WorkerCount && config.WorkerCount > 0 {
+		log.Printf("High priority workers (%d) must be fewer than total workers (%d), reserving %d",
+			config.HighPriorityWorkers, config.WorkerCount, config.WorkerCount-1)
+		config.HighPriorityWorkers = config.WorkerCount - 1
+	}
+	if config.HighPriorityWorkers < 0 {
+		config.HighPriorityWorkers = 0
+	}
+
+	if config.LeaseDuration == 0 {
+		config.LeaseDuration = 2 * time.Minute
+	}
+
+	if config.ReaperInterval == 0 {
+		config.ReaperInterval = 30 * time.Second
+	}
+
+	if config.BatchSize <= 0 {
+		config.BatchSize = 100
+	}
+
+	if config.MinConcurrency <= 0 {
+		config.MinConcurrency = 1
+	}
+
+	if config.MaxConcurrency < config.MinConcurrency {
+		config.MaxConcurrency = max(config.MinConcurrency, 50)
+	}
+
+	if config.LatencyTarget == 0 {
+		config.LatencyTarget = time.Second
+	}
+
+	if config.MaxInFlight <= 0 {
+		config.MaxInFlight = 200
+	}
+
+	if config.BackoffBase == 0 {
+		config.BackoffBase = 30 * time.Second
+	}
+
+	if config.BackoffMax == 0 {
+		config.BackoffMax = 30 * time.Minute
+	}
+
+	retryPolicies := map[string]RetryPolicy{
+		model.RetryPolicyFixed:       FixedRetryPolicy{Intervals: config.RetryIntervals},
+		model.RetryPolicyExponential: ExponentialRetryPolicy{Base: config.BackoffBase, Max: config.BackoffMax},
+	}
+
+	if _, ok := retryPolicies[config.DefaultRetryPolicy]; !ok {
+		if config.DefaultRetryPolicy != "" {
+			log.Printf("Unknown retry policy %q, using %q", config.DefaultRetryPolicy, model.RetryPolicyFixed)
+		}
+		config.DefaultRetryPolicy = model.RetryPolicyFixed
+	}
+
+	limiters := make([]*aimdLimiter, config.WorkerCount)
+	for i := range limiters {
+		limiters[i] = newAIMDLimiter(config.MinConcurrency, config.MaxConcurrency, config.LatencyTarget)
+	}
+
 	return &QueueWorker{
-		repo:      repo,
-		fcmClient: fcmClient,
-		config:    config,
-		ctx:       ctx,
-		cancel:    cancel,
+		repo:          repo,
+		tokenRepo:     tokenRepo,
+		fcmClient:     fcmClient,
+		config:        config,
+		instanceID:    newInstanceID(),
+		wake:          make(chan struct{}, max(config.WorkerCount, 1)),
+		limiters:      limiters,
+		retryPolicies: retryPolicies,
+		inFlight:      semaphore.NewWeighted(int64(config.MaxInFlight)),
+		ctx:           ctx,
+		cancel:        cancel,
+		sendCtx:       sendCtx,
+		cancelSends:   cancelSends,
+	}
+}
+
+func newInstanceID() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
+
+func (w *QueueWorker) claimID(workerID int) string {
+	return fmt.Sprintf("%s/%d", w.instanceID, workerID)
+}
+
+// lanePriority returns the only priority workerID may claim, or "" if it
+// serves every priority. The first HighPriorityWorkers goroutines form the
+// reserved high priority lane.
+func (w *QueueWorker) lanePriority(workerID int) string {
+	if workerID < w.config.HighPriorityWorkers {
+		return model.PriorityHigh
 	}
+	return ""
 }
 
 func (w *QueueWorker) Start() {
-	log.Printf("Starting queue worker with %d workers, poll interval: %s",
-		w.config.WorkerCount, w.config.PollInterval)
+	log.Printf("Starting queue worker with %d workers (%d reserved for high priority), poll interval: %s",
+		w.config.WorkerCount, w.config.HighPriorityWorkers, w.config.PollInterval)
 
 	for i := 0; i < w.config.WorkerCount; i++ {
 		w.wg.Add(1)
 		go w.workerLoop(i)
 	}
 
+	w.wg.Add(1)
+	go w.listenLoop()
+
 	w.wg.Add(1)
 	go w.cleanupLoop()
 
+	w.wg.Add(1)
+	go w.reaperLoop()
+
 	log.Println("Queue worker started successfully")
 }
+
+// Stop aborts in-flight sends right away; see Drain.
 func (w *QueueWorker) Stop() {
-	log.Println("Stopping queue worker...")
+	w.Drain(0)
+}
+
+// Drain stops claiming new tasks and waits up to timeout for in-flight sends
+// to finish. Sends still running at the deadline are aborted, and every task
+// this instance still holds goes back to pending without counting an attempt.
+func (w *QueueWorker) Drain(timeout time.Duration) {
+	log.Printf("Draining queue worker, waiting up to %s for in-flight sends...", timeout)
 	w.cancel()
 
 	if w.cleanupTicker != nil {
 		w.cleanupTicker.Stop()
 	}
 
-	w.wg.Wait()
+	done := make(chan struct{})
+	go func() {
+		w.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		log.Printf("Drain deadline of %s exceeded, aborting in-flight sends", timeout)
+		w.cancelSends()
+		<-done
+	}
+	w.cancelSends()
+
+	ctx, cancel := context.WithTimeout(context.Background(), resultRecordTimeout)
+	defer cancel()
+
+	released, err := w.repo.ReleaseClaimedTasks(ctx, w.instanceID+"/")
+	if err != nil {
+		log.Printf("Failed to release claimed tasks: %v", err)
+	} else if released > 0 {
+		log.Printf("Released %d claimed tasks back to the queue", released)
+	}
+
 	log.Println("Queue worker stopped")
 }
 
@@ -84,7 +283,11 @@ func (w *QueueWorker) workerLoop(workerID int) {
 	ticker := time.NewTicker(w.config.PollInterval)
 	defer ticker.Stop()
 
-	log.Printf("Worker %d started", workerID)
+	if priority := w.lanePriority(workerID); priority != "" {
+		log.Printf("Worker %d started (%s priority lane)", workerID, priority)
+	} else {
+		log.Printf("Worker %d started", workerID)
+	}
 
 	for {
 		select {
@@ -92,91 +295,555 @@ func (w *QueueWorker) workerLoop(workerID int) {
 			log.Printf("Worker %d stopping", workerID)
 			return
 		case <-ticker.C:
-			w.processBatch(workerID)
+		case <-w.wake:
+		}
+
+		// Keep draining while batches come back full instead of waiting for the next wake-up.
+		for w.ctx.Err() == nil {
+			if w.processBatch(workerID) < w.config.BatchSize {
+				break
+			}
 		}
 	}
 }
 
-func (w *QueueWorker) processBatch(workerID int) {
-	ctx, cancel := context.WithTimeout(w.ctx, 30*time.Second)
+// listenLoop turns task notifications into worker wake-ups. If the listening
+// connection drops, workers keep polling until it is re-established.
+func (w *QueueWorker) listenLoop() {
+	defer w.wg.Done()
+
+	for {
+		err := w.repo.ListenForNewTasks(w.ctx, w.notifyWorkers)
+		if w.ctx.Err() != nil {
+			return
+		}
+
+		log.Printf("Task listener disconnected: %v, reconnecting in %s", err, listenerReconnectDelay)
+
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-time.After(listenerReconnectDelay):
+		}
+	}
+}
+
+func (w *QueueWorker) notifyWorkers() {
+	select {
+	case w.wake <- struct{}{}:
+	default:
+		// Every worker already has a pending wake-up.
+	}
+}
+
+// processBatch claims and sends one batch, returning how many tasks were claimed.
+func (w *QueueWorker) processBatch(workerID int) int {
+	// Leave a quarter of the lease to record results before the claim expires.
+	ctx, cancel := context.WithTimeout(w.sendCtx, w.config.LeaseDuration*3/4)
 	defer cancel()
-	tasks, err := w.repo.GetPendingTasks(ctx, 10)
+	limit := w.config.BatchSize
+	breaker := w.fcmClient.Breaker()
+	probe := false
+	if breaker != nil {
+		var allowed bool
+		allowed, probe = breaker.Allow()
+		if !allowed {
+			// FCM is failing; leave tasks unclaimed so they keep their attempts.
+			return 0
+		}
+		if probe {
+			log.Printf("Worker %d: FCM circuit breaker half-open, sending a probe", workerID)
+			limit = 1
+		}
+	}
+
+	tasks, err := w.repo.GetPendingTasks(ctx, w.claimID(workerID), limit, w.config.LeaseDuration, w.lanePriority(workerID))
 	if err != nil {
 		log.Printf("Worker %d: failed to get pending tasks: %v", workerID, err)
-		return
+		if probe {
+			breaker.CancelProbe()
+		}
+		return 0
 	}
 
+	claimed := len(tasks)
+	tasks = w.expireTasks(ctx, workerID, tasks)
+	tasks = w.skipDeadTokens(ctx, workerID, tasks)
+
 	if len(tasks) == 0 {
-		return
+		if probe {
+			breaker.CancelProbe()
+		}
+		return claimed
 	}
 
 	log.Printf("Worker %d: processing %d tasks", workerID, len(tasks))
 
+	if probe {
+		ctx = fcm.WithProbe(ctx)
+	}
+	results := w.sendAll(ctx, workerID, tasks)
+
+	recordCtx, cancelRecord := context.WithTimeout(context.Background(), resultRecordTimeout)
+	defer cancelRecord()
+
+	succeeded := make(map[uuid.UUID]string, len(tasks))
+	attempts := make([]model.PushAttempt, 0, len(tasks))
+	var unsent []uuid.UUID
+	var deadTokens []model.TokenFailure
+	reachedFCM := false
+	for i, task := range tasks {
+		result := results[i]
+		if result.Skipped {
+			unsent = append(unsent, task.ID)
+			continue
+		}
+		if !result.Rejected {
+			reachedFCM = true
+		}
+
+		attempt := model.PushAttempt{
+			TaskID:     task.ID,
+			Attempt:    task.Attempts + 1,
+			WorkerID:   w.claimID(workerID),
+			StartedAt:  result.StartedAt,
+			FinishedAt: result.FinishedAt,
+			LatencyMs:  result.FinishedAt.Sub(result.StartedAt).Milliseconds(),
+		}
+
+		if result.Err != nil {
+			attempt.Outcome = w.handleTaskFailure(recordCtx, workerID, task, result.Err)
+			attempt.ErrorCode = stringPtr(string(fcm.CodeOf(result.Err)))
+			attempt.ErrorMessage = stringPtr(result.Err.Error())
+			attempts = append(attempts, attempt)
+
+			if task.TargetType == model.TargetToken && fcm.IsTokenInvalid(result.Err) {
+				deadTokens = append(deadTokens, model.TokenFailure{
+					Token:        task.Token,
+					ErrorCode:    string(fcm.CodeOf(result.Err)),
+					ErrorMessage: result.Err.Error(),
+				})
+			}
+			continue
+		}
+
+		succeeded[task.ID] = result.MessageID
+		attempt.Outcome = model.AttemptSuccess
+		attempt.FCMMessageID = stringPtr(result.MessageID)
+		attempts = append(attempts, attempt)
+	}
+
+	if probe && !reachedFCM {
+		breaker.CancelProbe()
+	}
+
+	if len(unsent) > 0 {
+		// These sends never started, so they go back to the queue as they were.
+		released, err := w.repo.ReleaseTasks(recordCtx, unsent, w.claimID(workerID))
+		if err != nil {
+			log.Printf("Worker %d: failed to release unsent tasks: %v", workerID, err)
+		} else {
+			log.Printf("Worker %d: released %d tasks that were not sent before the batch deadline", workerID, released)
+			w.logLeaseLost(workerID, len(unsent), released)
+		}
+	}
+
+	updated, err := w.repo.UpdateTasksSuccess(recordCtx, w.claimID(workerID), succeeded)
+	if err != nil {
+		log.Printf("Worker %d: failed to update task success: %v", workerID, err)
+	} else {
+		w.logLeaseLost(workerID, len(succeeded), updated)
+	}
+
+	if err := w.repo.RecordAttempts(recordCtx, attempts); err != nil {
+		log.Printf("Worker %d: failed to record attempts: %v", workerID, err)
+	}
+
+	if err := w.tokenRepo.MarkInvalid(recordCtx, deadTokens); err != nil {
+		log.Printf("Worker %d: failed to record invalid tokens: %v", workerID, err)
+	}
+
+	log.Printf("Worker %d: batch completed, success: %d, failed: %d, unsent: %d, concurrency limit: %d",
+		workerID, len(succeeded), len(attempts)-len(succeeded), len(unsent), w.limiters[workerID].Limit())
+
+	return claimed
+}
+
+func taskPayload(task *model.PushQueueTask) fcm.Payload {
+	payload := fcm.Payload{
+		Title:       task.Title,
+		Body:        task.Body,
+		Data:        task.Data,
+		Priority:    task.Priority,
+		DataOnly:    task.MessageType == model.MessageTypeData,
+		ExpiresAt:   task.ExpiresAt,
+		CollapseKey: task.CollapseKey,
+	}
+	if task.Platform != nil {
+		payload.Android = task.Platform.Android
+		payload.APNS = task.Platform.APNS
+		payload.Webpush = task.Platform.Webpush
+	}
+	return payload
+}
+
+// expireTasks marks tasks whose expiry has passed as expired, without
+// sending them, and returns the remaining tasks.
+func (w *QueueWorker) expireTasks(ctx context.Context, workerID int, tasks []*model.PushQueueTask) []*model.PushQueueTask {
+	now := time.Now()
+	live := make([]*model.PushQueueTask, 0, len(tasks))
+	var expired []uuid.UUID
 	for _, task := range tasks {
-		w.processTask(ctx, workerID, task)
+		if task.ExpiresAt != nil && !task.ExpiresAt.After(now) {
+			expired = append(expired, task.ID)
+			continue
+		}
+		live = append(live, task)
+	}
+	if len(expired) == 0 {
+		return tasks
 	}
+
+	closed, err := w.repo.ExpireTasks(ctx, expired, w.claimID(workerID))
+	if err != nil {
+		log.Printf("Worker %d: failed to expire tasks: %v", workerID, err)
+		return live
+	}
+
+	log.Printf("Worker %d: expired %d tasks", workerID, closed)
+	w.logLeaseLost(workerID, len(expired), closed)
+	return live
 }
 
-func (w *QueueWorker) processTask(ctx context.Context, workerID int, task *model.PushQueueTask) {
-	log.Printf("Worker %d: processing task %s (attempt %d/%d)",
-		workerID, task.ID, task.Attempts+1, task.MaxAttempts)
-	messageID, err := w.fcmClient.SendNotification(
-		ctx,
-		task.Token,
-		task.Title,
-		task.Body,
-		task.Data,
-		task.Priority,
-	)
+// skipDeadTokens marks tasks whose token FCM has already reported as dead as
+// skipped, without sending them, and returns the remaining tasks.
+func (w *QueueWorker) skipDeadTokens(ctx context.Context, workerID int, tasks []*model.PushQueueTask) []*model.PushQueueTask {
+	tokens := make([]string, 0, len(tasks))
+	for _, task := range tasks {
+		if task.TargetType == model.TargetToken {
+			tokens = append(tokens, task.Token)
+		}
+	}
+	if len(tokens) == 0 {
+		return tasks
+	}
+
+	invalid, err := w.tokenRepo.FindInvalid(ctx, tokens)
+	if err != nil {
+		log.Printf("Worker %d: failed to check token status, sending anyway: %v", workerID, err)
+		return tasks
+	}
+	if len(invalid) == 0 {
+		return tasks
+	}
+
+	live := make([]*model.PushQueueTask, 0, len(tasks))
+	var skipped []uuid.UUID
+	for _, task := range tasks {
+		if _, dead := invalid[task.Token]; dead {
+			skipped = append(skipped, task.ID)
+			continue
+		}
+		live = append(live, task)
+	}
 
+	closed, err := w.repo.SkipTasks(ctx, skipped, w.claimID(workerID), "token was reported invalid by FCM", model.ErrorCodeTokenInvalid)
 	if err != nil {
-		w.handleTaskFailure(ctx, workerID, task, err)
-		return
+		log.Printf("Worker %d: failed to skip tasks: %v", workerID, err)
+		return live
 	}
 
-	if err := w.repo.UpdateTaskSuccess(ctx, task.ID, messageID); err != nil {
-		log.Printf("Worker %d: failed to update task success: %v", workerID, err)
-		return
+	log.Printf("Worker %d: skipped %d tasks with invalid tokens", workerID, closed)
+	w.logLeaseLost(workerID, len(skipped), closed)
+	return live
+}
+
+// sendResult is the outcome of one send along with when it ran. Skipped sends
+// never started, because the batch deadline or shutdown came first. Rejected
+// sends failed to build a valid message and never reached FCM.
+type sendResult struct {
+	fcm.SendResult
+	Skipped    bool
+	Rejected   bool
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
+
+// buildMessages builds the FCM message of every task that is not raw. A task
+// whose message is invalid gets a rejected result instead of a message.
+func buildMessages(tasks []*model.PushQueueTask) ([]*messaging.Message, []sendResult) {
+	messages := make([]*messaging.Message, len(tasks))
+	results := make([]sendResult, len(tasks))
+	now := time.Now()
+
+	for i, task := range tasks {
+		if task.MessageType == model.MessageTypeRaw {
+			continue
+		}
+
+		target := fcm.Target{Token: task.Token, Topic: task.Topic, Condition: task.Condition}
+		message, err := fcm.BuildMessage(target, taskPayload(task))
+		if err != nil {
+			results[i] = sendResult{SendResult: fcm.SendResult{Err: err}, Rejected: true, StartedAt: now, FinishedAt: now}
+			continue
+		}
+		messages[i] = message
+	}
+	return messages, results
+}
+
+// sendRequests groups tasks into FCM requests, as indexes into tasks: each raw
+// message on its own, every task with a built message in SendEach chunks of up
+// to chunkSize.
+func sendRequests(tasks []*model.PushQueueTask, messages []*messaging.Message, chunkSize int) [][]int {
+	var requests [][]int
+	var chunk []int
+	for i, task := range tasks {
+		if task.MessageType == model.MessageTypeRaw {
+			requests = append(requests, []int{i})
+			continue
+		}
+		if messages[i] == nil {
+			continue
+		}
+
+		chunk = append(chunk, i)
+		if len(chunk) == chunkSize {
+			requests = append(requests, chunk)
+			chunk = nil
+		}
+	}
+	if len(chunk) > 0 {
+		requests = append(requests, chunk)
+	}
+	return requests
+}
+
+// send makes one FCM request for the tasks at indexes and returns one result
+// per index: a raw message is sent as stored, anything else is sent with
+// SendEach.
+func (w *QueueWorker) send(ctx context.Context, tasks []*model.PushQueueTask, messages []*messaging.Message, indexes []int) []fcm.SendResult {
+	if first := tasks[indexes[0]]; first.MessageType == model.MessageTypeRaw {
+		messageID, err := w.fcmClient.SendRaw(ctx, first.RawMessage)
+		return []fcm.SendResult{{MessageID: messageID, Err: err}}
+	}
+
+	chunk := make([]*messaging.Message, len(indexes))
+	for j, i := range indexes {
+		chunk[j] = messages[i]
+	}
+	return w.fcmClient.SendEach(ctx, chunk)
+}
+
+// chunkSize returns how many messages go in one SendEach request so that
+// count messages spread over limit concurrent requests. A chunk never exceeds
+// what FCM accepts in one call or the in-flight cap.
+func (w *QueueWorker) chunkSize(count, limit int) int {
+	size := (count + limit - 1) / max(limit, 1)
+	return max(min(size, fcm.MaxBatchSize, w.config.MaxInFlight), 1)
+}
+
+// sendAll sends tasks and returns one result per task. Every FCM request,
+// a single raw message or a SendEach chunk, takes one slot of the worker's
+// adaptive limit and one slot per message of the process-wide in-flight cap.
+func (w *QueueWorker) sendAll(ctx context.Context, workerID int, tasks []*model.PushQueueTask) []sendResult {
+	limiter := w.limiters[workerID]
+	messages, results := buildMessages(tasks)
+	var wg sync.WaitGroup
+
+	count := 0
+	for _, message := range messages {
+		if message != nil {
+			count++
+		}
+	}
+
+	for _, indexes := range sendRequests(tasks, messages, w.chunkSize(count, limiter.Limit())) {
+		if !w.acquire(ctx, limiter, len(indexes)) {
+			for _, i := range indexes {
+				results[i] = sendResult{Skipped: true}
+			}
+			continue
+		}
+
+		wg.Add(1)
+		go func(indexes []int) {
+			defer wg.Done()
+
+			start := time.Now()
+			sent := w.send(ctx, tasks, messages, indexes)
+			w.inFlight.Release(int64(len(indexes)))
+			limiter.Release(start, congestionError(sent))
+
+			finished := time.Now()
+			for j, i := range indexes {
+				results[i] = sendResult{SendResult: sent[j], StartedAt: start, FinishedAt: finished}
+			}
+		}(indexes)
+	}
+
+	wg.Wait()
+	return results
+}
+
+// acquire takes a slot of limiter and messages slots of the in-flight cap,
+// reporting false if ctx ended first.
+func (w *QueueWorker) acquire(ctx context.Context, limiter *aimdLimiter, messages int) bool {
+	if err := limiter.Acquire(ctx); err != nil {
+		return false
 	}
 
-	log.Printf("Worker %d: task %s completed successfully, FCM message ID: %s",
-		workerID, task.ID, messageID)
+	if err := w.inFlight.Acquire(ctx, int64(messages)); err != nil {
+		limiter.Cancel()
+		return false
+	}
+	return true
+}
+
+// congestionError returns the first error of a request that suggests FCM is
+// overloaded, so a whole chunk adjusts the limit once, like a single send.
+func congestionError(results []fcm.SendResult) error {
+	for _, result := range results {
+		if isCongestionSignal(result.Err) {
+			return result.Err
+		}
+	}
+	return nil
 }
 
-func (w *QueueWorker) handleTaskFailure(ctx context.Context, workerID int, task *model.PushQueueTask, err error) {
-	log.Printf("Worker %d: task %s failed: %v", workerID, task.ID, err)
+// handleTaskFailure records a failed send and returns the attempt outcome.
+func (w *QueueWorker) handleTaskFailure(ctx context.Context, workerID int, task *model.PushQueueTask, err error) string {
+	errorCode := fcm.CodeOf(err)
+	log.Printf("Worker %d: task %s failed (%s): %v", workerID, task.ID, errorCode, err)
 
 	nextAttempt := task.Attempts + 1
+	claimID := w.claimID(workerID)
+
+	if w.sendsAborted() {
+		// The drain deadline cut the send short; another instance will pick it up.
+		log.Printf("Worker %d: send of task %s aborted by shutdown, requeueing without counting the attempt",
+			workerID, task.ID)
+
+		if err := w.repo.RequeueTask(ctx, task.ID, claimID, err.Error(), string(errorCode), time.Now()); err != nil {
+			log.Printf("Worker %d: failed to requeue task: %v", workerID, err)
+		}
+		return model.AttemptRequeued
+	}
+
+	if breaker := w.fcmClient.Breaker(); breaker != nil && errorCode.IndicatesOutage() && breaker.State() != fcm.BreakerClosed {
+		// The circuit is open, so this failure says nothing about the task itself.
+		nextRetry := time.Now().Add(breaker.OpenTimeout())
+
+		log.Printf("Worker %d: FCM circuit breaker %s, requeueing task %s at %s without counting the attempt",
+			workerID, breaker.State(), task.ID, nextRetry.Format(time.RFC3339))
+
+		if err := w.repo.RequeueTask(ctx, task.ID, claimID, err.Error(), string(errorCode), nextRetry); err != nil {
+			log.Printf("Worker %d: failed to requeue task: %v", workerID, err)
+		}
+		return model.AttemptRequeued
+	}
+
+	if errorCode.Permanent() {
+		log.Printf("Worker %d: task %s failed permanently with %s, not retrying",
+			workerID, task.ID, errorCode)
+
+		if err := w.repo.UpdateTaskFailure(ctx, task.ID, claimID, err.Error(), string(errorCode), nil); err != nil {
+			log.Printf("Worker %d: failed to mark task as failed: %v", workerID, err)
+		}
+		return model.AttemptFailed
+	}
 
 	if nextAttempt < task.MaxAttempts {
-		nextRetry := w.calculateNextRetry(task.Attempts)
+		nextRetry := time.Now().Add(retryDelay(w.retryPolicyFor(task), task.Attempts, fcm.RetryAfterOf(err)))
+
+		if task.ExpiresAt != nil && !nextRetry.Before(*task.ExpiresAt) {
+			log.Printf("Worker %d: task %s expires before its next retry, marking it expired", workerID, task.ID)
+
+			if err := w.repo.ExpireAfterFailure(ctx, task.ID, claimID, err.Error()); err != nil {
+				log.Printf("Worker %d: failed to mark task as expired: %v", workerID, err)
+			}
+			return model.AttemptExpired
+		}
 
 		log.Printf("Worker %d: scheduling retry for task %s at %s (attempt %d/%d)",
 			workerID, task.ID, nextRetry.Format(time.RFC3339), nextAttempt+1, task.MaxAttempts)
 
-		if err := w.repo.UpdateTaskFailure(ctx, task.ID, err.Error(), &nextRetry); err != nil {
+		if err := w.repo.UpdateTaskFailure(ctx, task.ID, claimID, err.Error(), string(errorCode), &nextRetry); err != nil {
 			log.Printf("Worker %d: failed to schedule retry: %v", workerID, err)
 		}
-	} else {
-		log.Printf("Worker %d: task %s permanently failed after %d attempts",
-			workerID, task.ID, task.MaxAttempts)
+		return model.AttemptRetry
+	}
 
-		if err := w.repo.UpdateTaskFailure(ctx, task.ID, err.Error(), nil); err != nil {
-			log.Printf("Worker %d: failed to mark task as failed: %v", workerID, err)
+	log.Printf("Worker %d: task %s permanently failed after %d attempts",
+		workerID, task.ID, task.MaxAttempts)
+
+	if err := w.repo.UpdateTaskFailure(ctx, task.ID, claimID, err.Error(), string(errorCode), nil); err != nil {
+		log.Printf("Worker %d: failed to mark task as failed: %v", workerID, err)
+	}
+	return model.AttemptFailed
+}
+
+// logLeaseLost logs tasks whose results were not recorded because this worker
+// no longer held them, e.g. after the lease expired and another worker took over.
+func (w *QueueWorker) logLeaseLost(workerID, want int, recorded int64) {
+	if lost := int64(want) - recorded; lost > 0 {
+		log.Printf("Worker %d: %d tasks were no longer claimed by this worker, their results were not recorded", workerID, lost)
+	}
+}
+
+// sendsAborted reports whether the drain deadline has aborted in-flight sends.
+// It goes by the send context rather than the error, because the SDK wraps
+// transport errors without keeping context.Canceled in the chain.
+func (w *QueueWorker) sendsAborted() bool {
+	return w.sendCtx.Err() != nil
+}
+
+// retryPolicyFor picks the task's own retry policy, then its client's, then the default.
+func (w *QueueWorker) retryPolicyFor(task *model.PushQueueTask) RetryPolicy {
+	if task.RetryPolicy != nil {
+		if policy, ok := w.retryPolicies[*task.RetryPolicy]; ok {
+			return policy
+		}
+	}
+
+	if name, ok := w.config.ClientRetryPolicies[task.ClientID]; ok {
+		if policy, ok := w.retryPolicies[name]; ok {
+			return policy
 		}
 	}
+
+	return w.retryPolicies[w.config.DefaultRetryPolicy]
 }
 
-func (w *QueueWorker) calculateNextRetry(currentAttempt int) time.Time {
-	var delay time.Duration
+func (w *QueueWorker) reaperLoop() {
+	defer w.wg.Done()
 
-	if currentAttempt < len(w.config.RetryIntervals) {
-		delay = w.config.RetryIntervals[currentAttempt]
-	} else {
-		delay = w.config.RetryIntervals[len(w.config.RetryIntervals)-1]
+	ticker := time.NewTicker(w.config.ReaperInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.ctx.Done():
+			return
+		case <-ticker.C:
+			w.releaseExpiredLeases()
+		}
+	}
+}
+
+func (w *QueueWorker) releaseExpiredLeases() {
+	ctx, cancel := context.WithTimeout(w.ctx, 30*time.Second)
+	defer cancel()
+
+	released, err := w.repo.ReleaseExpiredLeases(ctx)
+	if err != nil {
+		log.Printf("Lease reaper failed: %v", err)
+		return
 	}
 
-	return time.Now().Add(delay)
+	if released > 0 {
+		log.Printf("Lease reaper: released %d tasks with expired leases", released)
+	}
 }
 
 func (w *QueueWorker) cleanupLoop() {
@@ -212,4 +879,38 @@ func (w *QueueWorker) runCleanup() {
 	if deleted > 0 {
 		log.Printf("Cleanup completed: deleted %d old tasks", deleted)
 	}
+
+	deleted, err = w.repo.CleanupDeadLetters(ctx, w.config.DeadLetterRetention)
+	if err != nil {
+		log.Printf("Dead letter cleanup failed: %v", err)
+		return
+	}
+
+	if deleted > 0 {
+		log.Printf("Dead letter cleanup completed: deleted %d entries older than %s", deleted, w.config.DeadLetterRetention)
+	}
+
+	deleted, err = w.repo.CleanupAttempts(ctx, w.config.CleanupAfter)
+	if err != nil {
+		log.Printf("Attempt log cleanup failed: %v", err)
+		return
+	}
+
+	if deleted > 0 {
+		log.Printf("Attempt log cleanup completed: deleted %d attempts", deleted)
+	}
+
+	deleted, err = w.repo.CleanupSendGroups(ctx, w.config.CleanupAfter)
+	if err != nil {
+		log.Printf("Send group cleanup failed: %v", err)
+		return
+	}
+
+	if deleted > 0 {
+		log.Printf("Send group cleanup completed: deleted %d groups", deleted)
+	}
+}
+
+func stringPtr(s string) *string {
+	return &s
 }
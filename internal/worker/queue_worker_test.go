@@ -0,0 +1,196 @@
+package worker
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"firebase.google.com/go/v4/messaging"
+	"github.com/galyym/fcm_push/internal/model"
+	"github.com/galyym/fcm_push/pkg/fcm"
+	"github.com/google/uuid"
+)
+
+// blockingSender holds every send until its context is done and then fails
+// the way the SDK does on an aborted request: with an error that does not
+// wrap context.Canceled.
+type blockingSender struct {
+	started chan struct{}
+}
+
+func (s *blockingSender) Breaker() *fcm.CircuitBreaker {
+	return nil
+}
+
+func (s *blockingSender) SendEach(ctx context.Context, messages []*messaging.Message) []fcm.SendResult {
+	close(s.started)
+	<-ctx.Done()
+
+	results := make([]fcm.SendResult, len(messages))
+	for i := range results {
+		results[i].Err = &fcm.Error{
+			Code: fcm.ErrorCodeUnknown,
+			Err:  errors.New(`error sending message: Post "https://fcm.googleapis.com/v1/projects/p/messages:send": context canceled`),
+		}
+	}
+	return results
+}
+
+func (s *blockingSender) SendRaw(ctx context.Context, message json.RawMessage) (string, error) {
+	return "", errors.New("unexpected raw send")
+}
+
+func TestSendsAbortedByDrain(t *testing.T) {
+	tests := []struct {
+		name        string
+		cancelSends bool
+		wantAborted bool
+	}{
+		{name: "drain deadline cancels the send", cancelSends: true, wantAborted: true},
+		{name: "batch deadline ends the send", cancelSends: false, wantAborted: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := NewQueueWorker(nil, nil, nil, Config{WorkerCount: 1})
+			sender := &blockingSender{started: make(chan struct{})}
+			w.fcmClient = sender
+
+			ctx, cancel := context.WithTimeout(w.sendCtx, 50*time.Millisecond)
+			defer cancel()
+
+			tasks := []*model.PushQueueTask{{
+				ID:          uuid.New(),
+				TargetType:  model.TargetToken,
+				Token:       "token",
+				MessageType: model.MessageTypeNotification,
+				Title:       "title",
+				Body:        "body",
+			}}
+
+			done := make(chan []sendResult)
+			go func() {
+				done <- w.sendAll(ctx, 0, tasks)
+			}()
+
+			<-sender.started
+			if tt.cancelSends {
+				w.cancelSends()
+			}
+			results := <-done
+
+			if results[0].Skipped {
+				t.Fatal("send was skipped, want it attempted")
+			}
+			if results[0].Err == nil {
+				t.Fatal("send succeeded, want an error")
+			}
+			if got := w.sendsAborted(); got != tt.wantAborted {
+				t.Errorf("sendsAborted() = %v, want %v (err: %v)", got, tt.wantAborted, results[0].Err)
+			}
+		})
+	}
+}
+
+// countingSender tracks how many SendEach requests run at once.
+type countingSender struct {
+	mu          sync.Mutex
+	active      int
+	maxActive   int
+	maxMessages int
+	sent        int
+}
+
+func (s *countingSender) Breaker() *fcm.CircuitBreaker {
+	return nil
+}
+
+func (s *countingSender) SendEach(ctx context.Context, messages []*messaging.Message) []fcm.SendResult {
+	s.mu.Lock()
+	s.active++
+	s.maxActive = max(s.maxActive, s.active)
+	s.maxMessages = max(s.maxMessages, len(messages))
+	s.sent += len(messages)
+	s.mu.Unlock()
+
+	time.Sleep(20 * time.Millisecond)
+
+	s.mu.Lock()
+	s.active--
+	s.mu.Unlock()
+
+	results := make([]fcm.SendResult, len(messages))
+	for i := range results {
+		results[i].MessageID = "projects/p/messages/1"
+	}
+	return results
+}
+
+func (s *countingSender) SendRaw(ctx context.Context, message json.RawMessage) (string, error) {
+	return "", errors.New("unexpected raw send")
+}
+
+func TestSendAllSplitsBatchAcrossConcurrentRequests(t *testing.T) {
+	w := NewQueueWorker(nil, nil, nil, Config{WorkerCount: 1})
+	sender := &countingSender{}
+	w.fcmClient = sender
+
+	tasks := make([]*model.PushQueueTask, w.config.BatchSize)
+	for i := range tasks {
+		tasks[i] = &model.PushQueueTask{
+			ID:          uuid.New(),
+			TargetType:  model.TargetToken,
+			Token:       "token",
+			MessageType: model.MessageTypeNotification,
+			Title:       "title",
+			Body:        "body",
+		}
+	}
+
+	limit := w.limiters[0].Limit()
+	results := w.sendAll(context.Background(), 0, tasks)
+
+	for i, result := range results {
+		if result.Skipped || result.Err != nil {
+			t.Fatalf("task %d: skipped = %v, err = %v, want it sent", i, result.Skipped, result.Err)
+		}
+	}
+	if sender.maxActive < 2 {
+		t.Errorf("max concurrent requests = %d, want more than 1", sender.maxActive)
+	}
+	if sender.maxMessages > (len(tasks)+limit-1)/limit {
+		t.Errorf("largest request = %d messages, want the batch spread over %d requests", sender.maxMessages, limit)
+	}
+}
+
+func TestSendAllRejectsInvalidMessages(t *testing.T) {
+	w := NewQueueWorker(nil, nil, nil, Config{WorkerCount: 1})
+	sender := &countingSender{}
+	w.fcmClient = sender
+
+	tasks := []*model.PushQueueTask{
+		{ID: uuid.New(), TargetType: model.TargetToken, Token: "token", Title: "title", Body: "body"},
+		{
+			ID: uuid.New(), TargetType: model.TargetToken, Token: "token", Title: "title", Body: "body",
+			Platform: &model.PlatformOptions{Android: &fcm.AndroidOptions{Color: "red"}},
+		},
+		{ID: uuid.New(), TargetType: model.TargetTopic, Topic: "news!", Title: "title", Body: "body"},
+	}
+
+	results := w.sendAll(context.Background(), 0, tasks)
+
+	if results[0].Rejected || results[0].Err != nil {
+		t.Errorf("valid task: rejected = %v, err = %v, want it sent", results[0].Rejected, results[0].Err)
+	}
+	for _, i := range []int{1, 2} {
+		if !results[i].Rejected || fcm.CodeOf(results[i].Err) != fcm.ErrorCodeInvalidArgument {
+			t.Errorf("task %d: rejected = %v, err = %v, want rejected with %s", i, results[i].Rejected, results[i].Err, fcm.ErrorCodeInvalidArgument)
+		}
+	}
+	if sender.sent != 1 {
+		t.Errorf("sent %d messages, want 1", sender.sent)
+	}
+}
@@ -0,0 +1,62 @@
+package worker
+
+import (
+	"math/rand/v2"
+	"time"
+)
+
+// RetryPolicy decides how long a failed task waits before its next attempt.
+type RetryPolicy interface {
+	// Delay returns the wait after attempt failed attempts (attempt starts at 0).
+	Delay(attempt int) time.Duration
+}
+
+// FixedRetryPolicy waits Intervals[attempt], repeating the last interval
+// once the list is exhausted.
+type FixedRetryPolicy struct {
+	Intervals []time.Duration
+}
+
+func (p FixedRetryPolicy) Delay(attempt int) time.Duration {
+	if len(p.Intervals) == 0 {
+		return time.Minute
+	}
+	if attempt < len(p.Intervals) {
+		return p.Intervals[attempt]
+	}
+	return p.Intervals[len(p.Intervals)-1]
+}
+
+// ExponentialRetryPolicy doubles the delay after every attempt starting at
+// Base, caps it at Max and applies full jitter: the actual delay is uniform in
+// [0, capped delay), so tasks that failed together do not retry together.
+type ExponentialRetryPolicy struct {
+	Base time.Duration
+	Max  time.Duration
+}
+
+func (p ExponentialRetryPolicy) Delay(attempt int) time.Duration {
+	ceiling := p.Max
+	if attempt < 32 {
+		if backoff := p.Base << attempt; backoff > 0 && backoff < p.Max {
+			ceiling = backoff
+		}
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling)
+}
+
+// retryDelay returns the delay before the next attempt under policy. A
+// Retry-After hint from FCM is a floor; up to half of it again is added as
+// jitter so a throttled burst is not retried at a single instant.
+func retryDelay(policy RetryPolicy, attempt int, retryAfter time.Duration) time.Duration {
+	delay := policy.Delay(attempt)
+	if retryAfter <= 0 {
+		return delay
+	}
+
+	hinted := retryAfter + rand.N(retryAfter/2+1)
+	return max(delay, hinted)
+}
@@ -0,0 +1,84 @@
+package worker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFixedRetryPolicyDelay(t *testing.T) {
+	policy := FixedRetryPolicy{Intervals: []time.Duration{time.Minute, 5 * time.Minute, 15 * time.Minute}}
+
+	tests := []struct {
+		name    string
+		policy  FixedRetryPolicy
+		attempt int
+		want    time.Duration
+	}{
+		{name: "first interval", policy: policy, attempt: 0, want: time.Minute},
+		{name: "last interval", policy: policy, attempt: 2, want: 15 * time.Minute},
+		{name: "last interval repeats", policy: policy, attempt: 7, want: 15 * time.Minute},
+		{name: "no intervals", policy: FixedRetryPolicy{}, attempt: 0, want: time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Delay(tt.attempt); got != tt.want {
+				t.Errorf("Delay(%d) = %s, want %s", tt.attempt, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExponentialRetryPolicyDelay(t *testing.T) {
+	policy := ExponentialRetryPolicy{Base: time.Second, Max: 10 * time.Second}
+
+	tests := []struct {
+		name    string
+		policy  ExponentialRetryPolicy
+		attempt int
+		ceiling time.Duration
+	}{
+		{name: "first attempt waits under the base", policy: policy, attempt: 0, ceiling: time.Second},
+		{name: "delay doubles per attempt", policy: policy, attempt: 3, ceiling: 8 * time.Second},
+		{name: "delay is capped", policy: policy, attempt: 4, ceiling: 10 * time.Second},
+		{name: "shift overflow is capped", policy: policy, attempt: 40, ceiling: 10 * time.Second},
+		{name: "zero base and max", policy: ExponentialRetryPolicy{}, attempt: 3, ceiling: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 200 {
+				got := tt.policy.Delay(tt.attempt)
+				if got < 0 || (tt.ceiling == 0 && got != 0) || (tt.ceiling > 0 && got >= tt.ceiling) {
+					t.Fatalf("Delay(%d) = %s, want in [0, %s)", tt.attempt, got, tt.ceiling)
+				}
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	policy := FixedRetryPolicy{Intervals: []time.Duration{time.Minute}}
+
+	tests := []struct {
+		name       string
+		retryAfter time.Duration
+		min        time.Duration
+		max        time.Duration
+	}{
+		{name: "no hint uses the policy", retryAfter: 0, min: time.Minute, max: time.Minute},
+		{name: "shorter hint keeps the policy delay", retryAfter: 10 * time.Second, min: time.Minute, max: time.Minute},
+		{name: "longer hint is a floor with jitter", retryAfter: 2 * time.Minute, min: 2 * time.Minute, max: 3 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for range 200 {
+				got := retryDelay(policy, 0, tt.retryAfter)
+				if got < tt.min || got > tt.max {
+					t.Fatalf("retryDelay() = %s, want in [%s, %s]", got, tt.min, tt.max)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS idx_push_queue_lease_expires_at;
+
+ALTER TABLE push_queue
+    DROP COLUMN IF EXISTS lease_expires_at,
+    DROP COLUMN IF EXISTS claimed_by;
@@ -0,0 +1,9 @@
+ALTER TABLE push_queue
+    ADD COLUMN IF NOT EXISTS claimed_by VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS lease_expires_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX IF NOT EXISTS idx_push_queue_lease_expires_at ON push_queue(lease_expires_at)
+    WHERE status = 'processing';
+
+COMMENT ON COLUMN push_queue.claimed_by IS 'Worker that currently holds the processing lease';
+COMMENT ON COLUMN push_queue.lease_expires_at IS 'When the processing lease expires and the task can be reclaimed';
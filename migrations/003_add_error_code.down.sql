@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_push_queue_error_code;
+
+ALTER TABLE push_queue
+    DROP COLUMN IF EXISTS error_code;
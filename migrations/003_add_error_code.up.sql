@@ -0,0 +1,7 @@
+ALTER TABLE push_queue
+    ADD COLUMN IF NOT EXISTS error_code VARCHAR(50);
+
+CREATE INDEX IF NOT EXISTS idx_push_queue_error_code ON push_queue(error_code)
+    WHERE error_code IS NOT NULL;
+
+COMMENT ON COLUMN push_queue.error_code IS 'FCM error code of the last failed attempt (e.g. UNREGISTERED, QUOTA_EXCEEDED)';
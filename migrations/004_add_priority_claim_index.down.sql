@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_push_queue_pending_priority;
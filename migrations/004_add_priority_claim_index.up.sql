@@ -0,0 +1,4 @@
+CREATE INDEX IF NOT EXISTS idx_push_queue_pending_priority ON push_queue(
+    (CASE WHEN priority = 'high' THEN 0 ELSE 1 END),
+    scheduled_at
+) WHERE status = 'pending';
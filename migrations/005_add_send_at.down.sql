@@ -0,0 +1,2 @@
+ALTER TABLE push_queue
+    DROP COLUMN IF EXISTS send_at;
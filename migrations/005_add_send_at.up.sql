@@ -0,0 +1,4 @@
+ALTER TABLE push_queue
+    ADD COLUMN IF NOT EXISTS send_at TIMESTAMP WITH TIME ZONE;
+
+COMMENT ON COLUMN push_queue.send_at IS 'Delivery time requested by the caller; NULL means send immediately';
@@ -0,0 +1,3 @@
+UPDATE push_queue SET status = 'failed', error_message = 'cancelled' WHERE status = 'cancelled';
+
+COMMENT ON COLUMN push_queue.status IS 'Task status: pending, processing, success, failed';
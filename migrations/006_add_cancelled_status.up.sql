@@ -0,0 +1 @@
+COMMENT ON COLUMN push_queue.status IS 'Task status: pending, processing, success, failed, cancelled';
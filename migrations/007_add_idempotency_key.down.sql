@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_push_queue_idempotency_key;
+
+ALTER TABLE push_queue
+    DROP COLUMN IF EXISTS idempotency_key;
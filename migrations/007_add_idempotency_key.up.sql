@@ -0,0 +1,7 @@
+ALTER TABLE push_queue
+    ADD COLUMN IF NOT EXISTS idempotency_key VARCHAR(255);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_push_queue_idempotency_key ON push_queue(client_id, idempotency_key)
+    WHERE idempotency_key IS NOT NULL;
+
+COMMENT ON COLUMN push_queue.idempotency_key IS 'Caller supplied key; unique per client_id while within the retention window';
@@ -0,0 +1,4 @@
+ALTER TABLE push_queue
+    ADD COLUMN IF NOT EXISTS retry_policy VARCHAR(20);
+
+COMMENT ON COLUMN push_queue.retry_policy IS 'Retry policy override for the task (fixed, exponential); NULL uses the client or default policy';
@@ -0,0 +1,35 @@
+CREATE TABLE IF NOT EXISTS push_dead_letter (
+    id UUID PRIMARY KEY,
+    token VARCHAR(255) NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    body TEXT NOT NULL,
+    data JSONB,
+    priority VARCHAR(20),
+    client_id VARCHAR(100),
+    attempts INTEGER NOT NULL,
+    max_attempts INTEGER NOT NULL,
+    retry_policy VARCHAR(20),
+    error_message TEXT,
+    error_code VARCHAR(50),
+    send_at TIMESTAMP WITH TIME ZONE,
+    task_created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    dead_lettered_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_push_dead_letter_dead_lettered_at ON push_dead_letter(dead_lettered_at DESC);
+
+CREATE INDEX idx_push_dead_letter_client_id ON push_dead_letter(client_id);
+
+INSERT INTO push_dead_letter (
+    id, token, title, body, data, priority, client_id, attempts, max_attempts,
+    retry_policy, error_message, error_code, send_at, task_created_at, dead_lettered_at
+)
+SELECT id, token, title, body, data, priority, client_id, attempts, max_attempts,
+       retry_policy, error_message, error_code, send_at, created_at, updated_at
+FROM push_queue
+WHERE status = 'failed'
+ON CONFLICT (id) DO NOTHING;
+
+COMMENT ON TABLE push_dead_letter IS 'Tasks that failed permanently; kept for DLQ_RETENTION_DAYS independently of push_queue cleanup';
+COMMENT ON COLUMN push_dead_letter.id IS 'ID of the failed push_queue task';
+COMMENT ON COLUMN push_dead_letter.task_created_at IS 'When the original task was enqueued';
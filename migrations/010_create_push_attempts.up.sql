@@ -0,0 +1,21 @@
+CREATE TABLE IF NOT EXISTS push_attempts (
+    id BIGSERIAL PRIMARY KEY,
+    task_id UUID NOT NULL,
+    attempt INTEGER NOT NULL,
+    worker_id VARCHAR(255) NOT NULL,
+    started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    finished_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    latency_ms BIGINT NOT NULL,
+    outcome VARCHAR(20) NOT NULL,
+    error_code VARCHAR(50),
+    error_message TEXT,
+    fcm_message_id VARCHAR(255)
+);
+
+CREATE INDEX idx_push_attempts_task_id ON push_attempts(task_id, started_at);
+
+CREATE INDEX idx_push_attempts_finished_at ON push_attempts(finished_at);
+
+COMMENT ON TABLE push_attempts IS 'One row per send attempt; not tied to push_queue so history outlives task cleanup';
+COMMENT ON COLUMN push_attempts.attempt IS 'Attempt number (1-based); requeued attempts do not advance it';
+COMMENT ON COLUMN push_attempts.outcome IS 'Attempt outcome: success, retry, failed, requeued, lease_expired';
@@ -0,0 +1,25 @@
+CREATE TABLE IF NOT EXISTS devices (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id VARCHAR(255) NOT NULL,
+    token VARCHAR(255) NOT NULL UNIQUE,
+    platform VARCHAR(20) NOT NULL,
+    app_version VARCHAR(50) NOT NULL DEFAULT '',
+    locale VARCHAR(35) NOT NULL DEFAULT '',
+    timezone VARCHAR(64) NOT NULL DEFAULT '',
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    last_seen_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_devices_user_id ON devices(user_id) WHERE active;
+
+CREATE TRIGGER update_devices_updated_at
+    BEFORE UPDATE ON devices
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+COMMENT ON TABLE devices IS 'FCM tokens registered for external users';
+COMMENT ON COLUMN devices.user_id IS 'User ID in the calling application';
+COMMENT ON COLUMN devices.platform IS 'Device platform: android, ios, web';
+COMMENT ON COLUMN devices.active IS 'Only active devices receive pushes sent to user_id';
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS token_status;
+
+COMMENT ON COLUMN push_queue.status IS 'Task status: pending, processing, success, failed, cancelled';
@@ -0,0 +1,17 @@
+CREATE TABLE IF NOT EXISTS token_status (
+    token_hash CHAR(64) PRIMARY KEY,
+    token VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    error_code VARCHAR(50),
+    error_message TEXT,
+    failure_count INTEGER NOT NULL DEFAULT 1,
+    first_failed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    last_failed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_token_status_last_failed_at ON token_status(status, last_failed_at);
+
+COMMENT ON TABLE token_status IS 'Health of FCM tokens as reported by FCM, keyed by SHA-256 of the token';
+COMMENT ON COLUMN token_status.status IS 'Token status: invalid';
+
+COMMENT ON COLUMN push_queue.status IS 'Task status: pending, processing, success, failed, cancelled, skipped';
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS topic_subscriptions (
+    token VARCHAR(255) NOT NULL,
+    topic VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (token, topic)
+);
+
+CREATE INDEX idx_topic_subscriptions_topic ON topic_subscriptions(topic);
+
+COMMENT ON TABLE topic_subscriptions IS 'Topic subscriptions made through this service; FCM does not expose them for listing';
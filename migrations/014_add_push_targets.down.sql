@@ -0,0 +1,16 @@
+DELETE FROM push_queue WHERE target_type <> 'token';
+DELETE FROM push_dead_letter WHERE target_type <> 'token';
+
+ALTER TABLE push_queue DROP CONSTRAINT IF EXISTS push_queue_target_check;
+
+ALTER TABLE push_queue
+    DROP COLUMN IF EXISTS condition,
+    DROP COLUMN IF EXISTS topic,
+    DROP COLUMN IF EXISTS target_type,
+    ALTER COLUMN token SET NOT NULL;
+
+ALTER TABLE push_dead_letter
+    DROP COLUMN IF EXISTS condition,
+    DROP COLUMN IF EXISTS topic,
+    DROP COLUMN IF EXISTS target_type,
+    ALTER COLUMN token SET NOT NULL;
@@ -0,0 +1,21 @@
+ALTER TABLE push_queue
+    ALTER COLUMN token DROP NOT NULL,
+    ADD COLUMN IF NOT EXISTS target_type VARCHAR(20) NOT NULL DEFAULT 'token',
+    ADD COLUMN IF NOT EXISTS topic VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS condition TEXT;
+
+ALTER TABLE push_queue
+    ADD CONSTRAINT push_queue_target_check CHECK (
+        (target_type = 'token' AND token IS NOT NULL AND topic IS NULL AND condition IS NULL) OR
+        (target_type = 'topic' AND topic IS NOT NULL AND token IS NULL AND condition IS NULL) OR
+        (target_type = 'condition' AND condition IS NOT NULL AND token IS NULL AND topic IS NULL)
+    );
+
+ALTER TABLE push_dead_letter
+    ALTER COLUMN token DROP NOT NULL,
+    ADD COLUMN IF NOT EXISTS target_type VARCHAR(20) NOT NULL DEFAULT 'token',
+    ADD COLUMN IF NOT EXISTS topic VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS condition TEXT;
+
+COMMENT ON COLUMN push_queue.target_type IS 'What the task is sent to: token, topic or condition; the matching column holds the target';
+COMMENT ON COLUMN push_queue.condition IS 'FCM topic condition, e.g. ''news'' in topics && ''sport'' in topics';
@@ -0,0 +1,3 @@
+ALTER TABLE push_queue DROP COLUMN IF EXISTS group_id;
+
+DROP TABLE IF EXISTS push_send_groups;
@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS push_send_groups (
+    id UUID PRIMARY KEY,
+    client_id VARCHAR(100),
+    idempotency_key VARCHAR(255),
+    title VARCHAR(255) NOT NULL,
+    body TEXT NOT NULL,
+    token_count INTEGER NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_push_send_groups_idempotency_key ON push_send_groups(client_id, idempotency_key)
+    WHERE idempotency_key IS NOT NULL;
+
+CREATE INDEX idx_push_send_groups_created_at ON push_send_groups(created_at);
+
+ALTER TABLE push_queue
+    ADD COLUMN IF NOT EXISTS group_id UUID REFERENCES push_send_groups(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_push_queue_group_id ON push_queue(group_id) WHERE group_id IS NOT NULL;
+
+COMMENT ON TABLE push_send_groups IS 'One multicast request; its tasks reference it through push_queue.group_id';
+COMMENT ON COLUMN push_send_groups.token_count IS 'Number of distinct tokens, and so of tasks, in the group';
@@ -0,0 +1,3 @@
+ALTER TABLE push_dead_letter DROP COLUMN IF EXISTS platform_options;
+
+ALTER TABLE push_queue DROP COLUMN IF EXISTS platform_options;
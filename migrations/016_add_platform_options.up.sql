@@ -0,0 +1,7 @@
+ALTER TABLE push_queue
+    ADD COLUMN IF NOT EXISTS platform_options JSONB;
+
+ALTER TABLE push_dead_letter
+    ADD COLUMN IF NOT EXISTS platform_options JSONB;
+
+COMMENT ON COLUMN push_queue.platform_options IS 'Android, APNs and Webpush notification settings: {"android": {...}, "apns": {...}, "webpush": {...}}';
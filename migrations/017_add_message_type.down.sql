@@ -0,0 +1,17 @@
+DELETE FROM push_queue WHERE message_type = 'data';
+DELETE FROM push_dead_letter WHERE message_type = 'data';
+DELETE FROM push_send_groups WHERE title IS NULL OR body IS NULL;
+
+ALTER TABLE push_send_groups
+    ALTER COLUMN title SET NOT NULL,
+    ALTER COLUMN body SET NOT NULL;
+
+ALTER TABLE push_dead_letter
+    DROP COLUMN IF EXISTS message_type,
+    ALTER COLUMN title SET NOT NULL,
+    ALTER COLUMN body SET NOT NULL;
+
+ALTER TABLE push_queue
+    DROP COLUMN IF EXISTS message_type,
+    ALTER COLUMN title SET NOT NULL,
+    ALTER COLUMN body SET NOT NULL;
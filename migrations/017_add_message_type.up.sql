@@ -0,0 +1,15 @@
+ALTER TABLE push_queue
+    ADD COLUMN IF NOT EXISTS message_type VARCHAR(20) NOT NULL DEFAULT 'notification',
+    ALTER COLUMN title DROP NOT NULL,
+    ALTER COLUMN body DROP NOT NULL;
+
+ALTER TABLE push_dead_letter
+    ADD COLUMN IF NOT EXISTS message_type VARCHAR(20) NOT NULL DEFAULT 'notification',
+    ALTER COLUMN title DROP NOT NULL,
+    ALTER COLUMN body DROP NOT NULL;
+
+ALTER TABLE push_send_groups
+    ALTER COLUMN title DROP NOT NULL,
+    ALTER COLUMN body DROP NOT NULL;
+
+COMMENT ON COLUMN push_queue.message_type IS 'notification, or data for data-only background messages without title and body';
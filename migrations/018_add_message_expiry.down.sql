@@ -0,0 +1,12 @@
+UPDATE push_queue SET status = 'cancelled' WHERE status = 'expired';
+
+ALTER TABLE push_dead_letter
+    DROP COLUMN IF EXISTS expires_at,
+    DROP COLUMN IF EXISTS collapse_key;
+
+ALTER TABLE push_queue
+    DROP COLUMN IF EXISTS expires_at,
+    DROP COLUMN IF EXISTS collapse_key;
+
+COMMENT ON COLUMN push_queue.status IS 'Task status: pending, processing, success, failed, cancelled, skipped';
+COMMENT ON COLUMN push_attempts.outcome IS 'Attempt outcome: success, retry, failed, requeued, lease_expired';
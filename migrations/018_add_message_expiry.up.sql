@@ -0,0 +1,12 @@
+ALTER TABLE push_queue
+    ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS collapse_key VARCHAR(64);
+
+ALTER TABLE push_dead_letter
+    ADD COLUMN IF NOT EXISTS expires_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN IF NOT EXISTS collapse_key VARCHAR(64);
+
+COMMENT ON COLUMN push_queue.expires_at IS 'Time after which the message is no longer worth delivering';
+COMMENT ON COLUMN push_queue.collapse_key IS 'Newer messages with the same key replace undelivered older ones on the device';
+COMMENT ON COLUMN push_queue.status IS 'Task status: pending, processing, success, failed, cancelled, skipped, expired';
+COMMENT ON COLUMN push_attempts.outcome IS 'Attempt outcome: success, retry, failed, requeued, lease_expired, expired';
@@ -0,0 +1,5 @@
+UPDATE push_queue SET status = 'cancelled' WHERE status = 'superseded';
+
+DROP INDEX IF EXISTS idx_push_queue_pending_collapse_key;
+
+COMMENT ON COLUMN push_queue.status IS 'Task status: pending, processing, success, failed, cancelled, skipped, expired';
@@ -0,0 +1,4 @@
+CREATE INDEX IF NOT EXISTS idx_push_queue_pending_collapse_key ON push_queue(client_id, collapse_key)
+    WHERE status = 'pending' AND collapse_key IS NOT NULL;
+
+COMMENT ON COLUMN push_queue.status IS 'Task status: pending, processing, success, failed, cancelled, skipped, expired, superseded';
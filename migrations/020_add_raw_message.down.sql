@@ -0,0 +1,8 @@
+DELETE FROM push_queue WHERE message_type = 'raw';
+DELETE FROM push_dead_letter WHERE message_type = 'raw';
+
+ALTER TABLE push_dead_letter DROP COLUMN IF EXISTS raw_message;
+
+ALTER TABLE push_queue DROP COLUMN IF EXISTS raw_message;
+
+COMMENT ON COLUMN push_queue.message_type IS 'notification, or data for data-only background messages without title and body';
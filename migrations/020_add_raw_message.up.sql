@@ -0,0 +1,6 @@
+ALTER TABLE push_queue ADD COLUMN IF NOT EXISTS raw_message JSONB;
+
+ALTER TABLE push_dead_letter ADD COLUMN IF NOT EXISTS raw_message JSONB;
+
+COMMENT ON COLUMN push_queue.raw_message IS 'Complete FCM HTTP v1 message sent as given, for message_type raw';
+COMMENT ON COLUMN push_queue.message_type IS 'notification, data for data-only background messages without title and body, or raw for raw_message';
@@ -0,0 +1,212 @@
+package fcm
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"
+	BreakerOpen     BreakerState = "open"
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+type BreakerConfig struct {
+	// Window is the period over which the error rate is measured.
+	Window time.Duration
+	// MinRequests is how many sends a window needs before it can trip the breaker.
+	MinRequests int
+	// FailureRatio is the share of outage errors that opens the breaker.
+	FailureRatio float64
+	// OpenTimeout is how long the breaker stays open before allowing a probe.
+	OpenTimeout time.Duration
+}
+
+// BreakerStatus is a point-in-time view of a CircuitBreaker.
+type BreakerStatus struct {
+	State       BreakerState
+	Requests    int
+	Failures    int
+	FailureRate float64
+	OpenedAt    *time.Time
+}
+
+// CircuitBreaker tracks FCM quota and outage errors across all senders. When
+// their rate stays above FailureRatio it opens, telling callers to stop
+// sending. After OpenTimeout a single probe is allowed through (half-open);
+// its outcome closes the breaker or opens it again.
+type CircuitBreaker struct {
+	mu          sync.Mutex
+	config      BreakerConfig
+	state       BreakerState
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+	probing     bool
+}
+
+func NewCircuitBreaker(config BreakerConfig) *CircuitBreaker {
+	if config.Window == 0 {
+		config.Window = 30 * time.Second
+	}
+	if config.MinRequests <= 0 {
+		config.MinRequests = 20
+	}
+	if config.FailureRatio <= 0 || config.FailureRatio > 1 {
+		config.FailureRatio = 0.5
+	}
+	if config.OpenTimeout == 0 {
+		config.OpenTimeout = 30 * time.Second
+	}
+
+	return &CircuitBreaker{
+		config:      config,
+		state:       BreakerClosed,
+		windowStart: time.Now(),
+	}
+}
+
+// Allow reports whether new work may be sent. While half-open only one caller
+// at a time is allowed, and probe is true for it: it should send a single
+// message with a context from WithProbe, so the outcome is reported with
+// RecordProbe, or call CancelProbe if it had nothing to send.
+func (b *CircuitBreaker) Allow() (allowed, probe bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerClosed:
+		return true, false
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.config.OpenTimeout {
+			return false, false
+		}
+		b.state = BreakerHalfOpen
+	}
+
+	if b.probing {
+		return false, false
+	}
+	b.probing = true
+	return true, true
+}
+
+// CancelProbe releases a probe slot that was granted by Allow but not used.
+func (b *CircuitBreaker) CancelProbe() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.probing = false
+}
+
+// Record feeds the outcome of one send into the breaker. While half-open only
+// the probe's outcome counts, so sends that were already in flight when the
+// breaker opened cannot close it again.
+func (b *CircuitBreaker) Record(err error) {
+	outage := CodeOf(err).IndicatesOutage()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerHalfOpen {
+		return
+	}
+
+	now := time.Now()
+
+	if now.Sub(b.windowStart) > b.config.Window {
+		b.resetWindow(now)
+	}
+
+	b.requests++
+	if outage {
+		b.failures++
+	}
+
+	if b.state == BreakerClosed && b.requests >= b.config.MinRequests &&
+		float64(b.failures)/float64(b.requests) >= b.config.FailureRatio {
+		b.open(now)
+	}
+}
+
+// RecordProbe reports the outcome of the probe granted by Allow: an outage
+// error opens the breaker again, anything else closes it.
+func (b *CircuitBreaker) RecordProbe(err error) {
+	outage := CodeOf(err).IndicatesOutage()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if b.state != BreakerHalfOpen {
+		return
+	}
+
+	now := time.Now()
+	if outage {
+		b.open(now)
+	} else {
+		b.state = BreakerClosed
+		b.resetWindow(now)
+	}
+}
+
+type probeKey struct{}
+
+// WithProbe marks sends made with ctx as the breaker's half-open probe.
+func WithProbe(ctx context.Context) context.Context {
+	return context.WithValue(ctx, probeKey{}, true)
+}
+
+func isProbe(ctx context.Context) bool {
+	probe, _ := ctx.Value(probeKey{}).(bool)
+	return probe
+}
+
+// State returns the current breaker state.
+func (b *CircuitBreaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Status returns the breaker state along with the current window counters.
+func (b *CircuitBreaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := BreakerStatus{
+		State:    b.state,
+		Requests: b.requests,
+		Failures: b.failures,
+	}
+	if b.requests > 0 {
+		status.FailureRate = float64(b.failures) / float64(b.requests)
+	}
+	if b.state != BreakerClosed {
+		openedAt := b.openedAt
+		status.OpenedAt = &openedAt
+	}
+
+	return status
+}
+
+// OpenTimeout returns how long the breaker stays open before probing.
+func (b *CircuitBreaker) OpenTimeout() time.Duration {
+	return b.config.OpenTimeout
+}
+
+func (b *CircuitBreaker) open(now time.Time) {
+	b.state = BreakerOpen
+	b.openedAt = now
+	b.resetWindow(now)
+}
+
+func (b *CircuitBreaker) resetWindow(now time.Time) {
+	b.windowStart = now
+	b.requests = 0
+	b.failures = 0
+}
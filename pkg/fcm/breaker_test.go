@@ -0,0 +1,166 @@
+package fcm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var (
+	errOutage  = &Error{Code: ErrorCodeUnavailable, Err: errors.New("unavailable")}
+	errInvalid = &Error{Code: ErrorCodeInvalidArgument, Err: errors.New("invalid argument")}
+)
+
+func newTestBreaker() *CircuitBreaker {
+	return NewCircuitBreaker(BreakerConfig{
+		Window:       time.Minute,
+		MinRequests:  4,
+		FailureRatio: 0.5,
+		OpenTimeout:  time.Minute,
+	})
+}
+
+// halfOpen returns a breaker whose probe has been granted to the caller.
+func halfOpen(t *testing.T) *CircuitBreaker {
+	t.Helper()
+
+	b := newTestBreaker()
+	b.mu.Lock()
+	b.open(time.Now().Add(-2 * time.Minute))
+	b.mu.Unlock()
+
+	if allowed, probe := b.Allow(); !allowed || !probe {
+		t.Fatalf("Allow() = %v, %v, want true, true", allowed, probe)
+	}
+	return b
+}
+
+func TestCircuitBreakerRecord(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []error
+		want    BreakerState
+	}{
+		{name: "no requests", want: BreakerClosed},
+		{name: "failures below the minimum requests", results: []error{errOutage, errOutage, errOutage}, want: BreakerClosed},
+		{name: "failure ratio reached", results: []error{nil, errOutage, nil, errOutage}, want: BreakerOpen},
+		{name: "failure ratio not reached", results: []error{nil, errOutage, nil, nil}, want: BreakerClosed},
+		{name: "permanent errors are not outages", results: []error{errInvalid, errInvalid, errInvalid, errInvalid}, want: BreakerClosed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestBreaker()
+			for _, err := range tt.results {
+				b.Record(err)
+			}
+			if got := b.State(); got != tt.want {
+				t.Errorf("State() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerAllow(t *testing.T) {
+	b := newTestBreaker()
+	if allowed, probe := b.Allow(); !allowed || probe {
+		t.Fatalf("closed: Allow() = %v, %v, want true, false", allowed, probe)
+	}
+
+	b.mu.Lock()
+	b.open(time.Now())
+	b.mu.Unlock()
+	if allowed, _ := b.Allow(); allowed {
+		t.Fatal("open: Allow() = true before OpenTimeout, want false")
+	}
+
+	b.mu.Lock()
+	b.openedAt = time.Now().Add(-2 * time.Minute)
+	b.mu.Unlock()
+	if allowed, probe := b.Allow(); !allowed || !probe {
+		t.Fatalf("after OpenTimeout: Allow() = %v, %v, want true, true", allowed, probe)
+	}
+	if allowed, _ := b.Allow(); allowed {
+		t.Fatal("half-open: second Allow() = true while probing, want false")
+	}
+
+	b.CancelProbe()
+	if allowed, probe := b.Allow(); !allowed || !probe {
+		t.Fatalf("after CancelProbe: Allow() = %v, %v, want true, true", allowed, probe)
+	}
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	tests := []struct {
+		name   string
+		record func(b *CircuitBreaker)
+		want   BreakerState
+	}{
+		{
+			name:   "successful probe closes",
+			record: func(b *CircuitBreaker) { b.RecordProbe(nil) },
+			want:   BreakerClosed,
+		},
+		{
+			name:   "failed probe opens again",
+			record: func(b *CircuitBreaker) { b.RecordProbe(errOutage) },
+			want:   BreakerOpen,
+		},
+		{
+			name:   "permanent probe error closes",
+			record: func(b *CircuitBreaker) { b.RecordProbe(errInvalid) },
+			want:   BreakerClosed,
+		},
+		{
+			name:   "late success of another send is ignored",
+			record: func(b *CircuitBreaker) { b.Record(nil) },
+			want:   BreakerHalfOpen,
+		},
+		{
+			name:   "late failure of another send is ignored",
+			record: func(b *CircuitBreaker) { b.Record(errOutage) },
+			want:   BreakerHalfOpen,
+		},
+		{
+			name: "probe decides after a late send",
+			record: func(b *CircuitBreaker) {
+				b.Record(nil)
+				b.RecordProbe(errOutage)
+			},
+			want: BreakerOpen,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := halfOpen(t)
+			tt.record(b)
+			if got := b.State(); got != tt.want {
+				t.Errorf("State() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientRecordProbe(t *testing.T) {
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want BreakerState
+	}{
+		{name: "probe context resolves the probe", ctx: WithProbe(context.Background()), want: BreakerClosed},
+		{name: "plain context does not", ctx: context.Background(), want: BreakerHalfOpen},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := halfOpen(t)
+			c := &Client{breaker: b}
+			c.record(tt.ctx, nil)
+			if got := b.State(); got != tt.want {
+				t.Errorf("State() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
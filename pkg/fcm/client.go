@@ -3,14 +3,30 @@ package fcm
 import (
 	"context"
 	"fmt"
+	"net/http"
 
 	firebase "firebase.google.com/go/v4"
 	"firebase.google.com/go/v4/messaging"
 	"google.golang.org/api/option"
+	"google.golang.org/api/transport"
 )
 
+// MaxBatchSize is the largest number of messages FCM accepts in one SendEach call.
+const MaxBatchSize = 500
+
+// SendResult is the outcome of one message sent with SendEach. Err is an *Error.
+type SendResult struct {
+	MessageID string
+	Err       error
+}
+
 type Client struct {
 	messagingClient *messaging.Client
+	breaker         *CircuitBreaker
+
+	// httpClient and sendURL serve SendRaw, which calls the HTTP v1 API directly.
+	httpClient *http.Client
+	sendURL    string
 }
 
 func NewClient(ctx context.Context, credentialsPath string) (*Client, error) {
@@ -25,61 +41,126 @@ func NewClient(ctx context.Context, credentialsPath string) (*Client, error) {
 		return nil, fmt.Errorf("error getting messaging client: %w", err)
 	}
 
+	creds, err := transport.Creds(ctx, opt, option.WithScopes(messagingScope))
+	if err != nil {
+		return nil, fmt.Errorf("error reading credentials: %w", err)
+	}
+	if creds.ProjectID == "" {
+		return nil, fmt.Errorf("credentials do not specify a project ID")
+	}
+
+	httpClient, _, err := transport.NewHTTPClient(ctx, opt, option.WithScopes(messagingScope))
+	if err != nil {
+		return nil, fmt.Errorf("error creating http client: %w", err)
+	}
+
 	return &Client{
 		messagingClient: messagingClient,
+		httpClient:      httpClient,
+		sendURL:         fmt.Sprintf(sendURLTemplate, creds.ProjectID),
 	}, nil
 }
 
-func (c *Client) SendNotification(ctx context.Context, token, title, body string, data map[string]string, priority string) (string, error) {
-	message := &messaging.Message{
-		Token: token,
-		Notification: &messaging.Notification{
-			Title: title,
-			Body:  body,
-		},
-		Data: data,
+// UseCircuitBreaker makes the client report every send outcome to breaker.
+func (c *Client) UseCircuitBreaker(breaker *CircuitBreaker) {
+	c.breaker = breaker
+}
+
+// Breaker returns the circuit breaker in use, or nil.
+func (c *Client) Breaker() *CircuitBreaker {
+	return c.breaker
+}
+
+func (c *Client) record(ctx context.Context, err error) {
+	switch {
+	case c.breaker == nil:
+	case isProbe(ctx):
+		c.breaker.RecordProbe(err)
+	default:
+		c.breaker.Record(err)
 	}
+}
 
-	if priority == "high" {
-		message.Android = &messaging.AndroidConfig{
-			Priority: "high",
-		}
-		message.APNS = &messaging.APNSConfig{
-			Headers: map[string]string{
-				"apns-priority": "10",
-			},
-		}
+// BuildMessage builds the FCM message sent for a single push notification.
+// It checks target and payload first, so a message it returns passes the
+// SDK's own validation. A returned error is an *Error.
+func BuildMessage(target Target, payload Payload) (*messaging.Message, error) {
+	if err := target.Validate(); err != nil {
+		return nil, &Error{Code: ErrorCodeInvalidArgument, Err: fmt.Errorf("invalid message: %w", err)}
+	}
+	if err := payload.Validate(); err != nil {
+		return nil, &Error{Code: ErrorCodeInvalidArgument, Err: fmt.Errorf("invalid message: %w", err)}
 	}
 
-	messageID, err := c.messagingClient.Send(ctx, message)
-	if err != nil {
-		return "", fmt.Errorf("error sending message: %w", err)
+	message := &messaging.Message{
+		Data:    payload.Data,
+		Android: payload.android(),
+		APNS:    payload.apns(),
+		Webpush: payload.webpush(),
 	}
+	if !payload.DataOnly {
+		message.Notification = &messaging.Notification{
+			Title: payload.Title,
+			Body:  payload.Body,
+		}
+	}
+	target.apply(message)
 
-	return messageID, nil
+	return message, nil
 }
 
-func (c *Client) SendBatchNotifications(ctx context.Context, messages []*messaging.Message) (*messaging.BatchResponse, error) {
-	br, err := c.messagingClient.SendEach(ctx, messages)
-	if err != nil {
-		return nil, fmt.Errorf("error sending batch messages: %w", err)
+// SendEach sends messages in chunks of MaxBatchSize and returns one result
+// per message, in order. Messages should come from BuildMessage: the SDK
+// rejects a whole chunk, with no per-message responses, if any message in it
+// fails its validation, and every message in such a chunk fails with the
+// chunk's error.
+func (c *Client) SendEach(ctx context.Context, messages []*messaging.Message) []SendResult {
+	results := make([]SendResult, len(messages))
+
+	for start := 0; start < len(messages); start += MaxBatchSize {
+		end := min(start+MaxBatchSize, len(messages))
+		chunk := messages[start:end]
+
+		br, err := c.messagingClient.SendEach(ctx, chunk)
+		if err != nil {
+			sendErr := newError(err)
+			for i := range chunk {
+				results[start+i] = SendResult{Err: sendErr}
+				c.record(ctx, sendErr)
+			}
+			continue
+		}
+
+		for i, resp := range br.Responses {
+			if resp.Success {
+				results[start+i] = SendResult{MessageID: resp.MessageID}
+				c.record(ctx, nil)
+				continue
+			}
+			results[start+i] = SendResult{Err: newError(resp.Error)}
+			c.record(ctx, results[start+i].Err)
+		}
 	}
 
-	return br, nil
+	return results
 }
 
-func (c *Client) SubscribeToTopic(ctx context.Context, tokens []string, topic string) error {
-	_, err := c.messagingClient.SubscribeToTopic(ctx, tokens, topic)
+// SubscribeToTopic subscribes up to 1000 tokens to topic. Per-token failures
+// are reported in the response rather than as an error.
+func (c *Client) SubscribeToTopic(ctx context.Context, tokens []string, topic string) (*messaging.TopicManagementResponse, error) {
+	response, err := c.messagingClient.SubscribeToTopic(ctx, tokens, topic)
 	if err != nil {
-		return fmt.Errorf("error subscribing to topic: %w", err)
+		return nil, fmt.Errorf("error subscribing to topic: %w", err)
 	}
-	return nil
+	return response, nil
 }
 
-func (c *Client) UnsubscribeFromTopic(ctx context.Context, tokens []string, topic string) error {
-	_, err := c.messagingClient.UnsubscribeFromTopic(ctx, tokens, topic)
+// UnsubscribeFromTopic unsubscribes up to 1000 tokens from topic. Per-token
+// failures are reported in the response rather than as an error.
+func (c *Client) UnsubscribeFromTopic(ctx context.Context, tokens []string, topic string) (*messaging.TopicManagementResponse, error) {
+	response, err := c.messagingClient.UnsubscribeFromTopic(ctx, tokens, topic)
 	if err != nil {
-		return fmt.Errorf("error unsubscribing from topic: %w", err)
+		return nil, fmt.Errorf("error unsubscribing from topic: %w", err)
 	}
-	return nil
+	return response, nil
 }
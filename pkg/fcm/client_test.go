@@ -0,0 +1,63 @@
+package fcm
+
+import "testing"
+
+func TestBuildMessage(t *testing.T) {
+	notification := Payload{Title: "title", Body: "body"}
+
+	tests := []struct {
+		name    string
+		target  Target
+		payload Payload
+		wantErr bool
+	}{
+		{name: "token", target: Target{Token: "token"}, payload: notification},
+		{name: "topic", target: Target{Topic: "news"}, payload: notification},
+		{name: "condition", target: Target{Condition: "'news' in topics"}, payload: notification},
+		{name: "no target", payload: notification, wantErr: true},
+		{name: "token and topic", target: Target{Token: "token", Topic: "news"}, payload: notification, wantErr: true},
+		{name: "malformed topic", target: Target{Topic: "news!"}, payload: notification, wantErr: true},
+		{name: "malformed condition", target: Target{Condition: "news in topics"}, payload: notification, wantErr: true},
+		{
+			name:    "invalid android color",
+			target:  Target{Token: "token"},
+			payload: Payload{Title: "title", Body: "body", Android: &AndroidOptions{Color: "red"}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid android image",
+			target:  Target{Token: "token"},
+			payload: Payload{Title: "title", Body: "body", Android: &AndroidOptions{Image: "order.png"}},
+			wantErr: true,
+		},
+		{
+			name:    "webpush link without https",
+			target:  Target{Token: "token"},
+			payload: Payload{Title: "title", Body: "body", Webpush: &WebpushOptions{Link: "http://example.com"}},
+			wantErr: true,
+		},
+		{
+			name:    "data-only ignores platform options",
+			target:  Target{Token: "token"},
+			payload: Payload{DataOnly: true, Data: map[string]string{"action": "sync"}, Android: &AndroidOptions{Color: "red"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			message, err := BuildMessage(tt.target, tt.payload)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("BuildMessage() = %v, want error: %v", err, tt.wantErr)
+			}
+			if err != nil {
+				if code := CodeOf(err); code != ErrorCodeInvalidArgument {
+					t.Errorf("error code = %s, want %s", code, ErrorCodeInvalidArgument)
+				}
+				return
+			}
+			if message.Token != tt.target.Token || message.Topic != tt.target.Topic || message.Condition != tt.target.Condition {
+				t.Errorf("message target = %q/%q/%q, want %+v", message.Token, message.Topic, message.Condition, tt.target)
+			}
+		})
+	}
+}
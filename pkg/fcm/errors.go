@@ -0,0 +1,162 @@
+package fcm
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"firebase.google.com/go/v4/errorutils"
+	"firebase.google.com/go/v4/messaging"
+)
+
+// ErrorCode is the FCM v1 error category of a failed send.
+type ErrorCode string
+
+const (
+	ErrorCodeUnregistered     ErrorCode = "UNREGISTERED"
+	ErrorCodeInvalidArgument  ErrorCode = "INVALID_ARGUMENT"
+	ErrorCodeSenderIDMismatch ErrorCode = "SENDER_ID_MISMATCH"
+	ErrorCodeThirdPartyAuth   ErrorCode = "THIRD_PARTY_AUTH_ERROR"
+	ErrorCodeQuotaExceeded    ErrorCode = "QUOTA_EXCEEDED"
+	ErrorCodeUnavailable      ErrorCode = "UNAVAILABLE"
+	ErrorCodeInternal         ErrorCode = "INTERNAL"
+	ErrorCodeUnknown          ErrorCode = "UNKNOWN"
+)
+
+// Permanent reports whether retrying the same message can never succeed.
+func (c ErrorCode) Permanent() bool {
+	switch c {
+	case ErrorCodeUnregistered, ErrorCodeInvalidArgument, ErrorCodeSenderIDMismatch, ErrorCodeThirdPartyAuth:
+		return true
+	default:
+		return false
+	}
+}
+
+// IndicatesOutage reports whether the code means FCM itself is throttling or
+// failing, rather than rejecting one particular message.
+func (c ErrorCode) IndicatesOutage() bool {
+	switch c {
+	case ErrorCodeQuotaExceeded, ErrorCodeUnavailable, ErrorCodeInternal:
+		return true
+	default:
+		return false
+	}
+}
+
+// Error is returned by Client send methods and carries the classified FCM error code.
+type Error struct {
+	Code ErrorCode
+	// RetryAfter is the delay FCM asked for in a Retry-After header, if any.
+	RetryAfter time.Duration
+	Err        error
+}
+
+func newError(err error) *Error {
+	return &Error{
+		Code:       ClassifyError(err),
+		RetryAfter: parseRetryAfter(errorutils.HTTPResponse(err)),
+		Err:        fmt.Errorf("error sending message: %w", err),
+	}
+}
+
+// parseRetryAfter reads a Retry-After header given either in seconds or as an HTTP date.
+func parseRetryAfter(resp *http.Response) time.Duration {
+	if resp == nil {
+		return 0
+	}
+
+	value := resp.Header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay
+		}
+	}
+
+	return 0
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// ClassifyError maps an error returned by the messaging package to an ErrorCode.
+func ClassifyError(err error) ErrorCode {
+	switch {
+	case err == nil:
+		return ""
+	case messaging.IsUnregistered(err):
+		return ErrorCodeUnregistered
+	case messaging.IsInvalidArgument(err):
+		return ErrorCodeInvalidArgument
+	case messaging.IsSenderIDMismatch(err):
+		return ErrorCodeSenderIDMismatch
+	case messaging.IsThirdPartyAuthError(err):
+		return ErrorCodeThirdPartyAuth
+	case messaging.IsQuotaExceeded(err):
+		return ErrorCodeQuotaExceeded
+	case messaging.IsUnavailable(err):
+		return ErrorCodeUnavailable
+	case messaging.IsInternal(err):
+		return ErrorCodeInternal
+	default:
+		return ErrorCodeUnknown
+	}
+}
+
+// CodeOf returns the ErrorCode carried by err, or ErrorCodeUnknown if err was
+// not produced by this package.
+func CodeOf(err error) ErrorCode {
+	var fcmErr *Error
+	if errors.As(err, &fcmErr) {
+		return fcmErr.Code
+	}
+	if err == nil {
+		return ""
+	}
+	return ErrorCodeUnknown
+}
+
+// RetryAfterOf returns the Retry-After delay carried by err, or zero.
+func RetryAfterOf(err error) time.Duration {
+	var fcmErr *Error
+	if errors.As(err, &fcmErr) {
+		return fcmErr.RetryAfter
+	}
+	return 0
+}
+
+// IsPermanent reports whether err is an FCM error that should not be retried.
+func IsPermanent(err error) bool {
+	return CodeOf(err).Permanent()
+}
+
+// IsTokenInvalid reports whether err proves the target token can never
+// receive messages again: it was unregistered, belongs to another sender, or
+// FCM rejected it as malformed. Other INVALID_ARGUMENT errors concern the
+// message rather than the token and are not counted.
+func IsTokenInvalid(err error) bool {
+	switch CodeOf(err) {
+	case ErrorCodeUnregistered, ErrorCodeSenderIDMismatch:
+		return true
+	case ErrorCodeInvalidArgument:
+		return strings.Contains(strings.ToLower(err.Error()), "registration token")
+	default:
+		return false
+	}
+}
@@ -0,0 +1,41 @@
+package fcm
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		min    time.Duration
+		max    time.Duration
+	}{
+		{name: "missing header", header: "", min: 0, max: 0},
+		{name: "seconds", header: "120", min: 120 * time.Second, max: 120 * time.Second},
+		{name: "zero seconds", header: "0", min: 0, max: 0},
+		{name: "negative seconds", header: "-5", min: 0, max: 0},
+		{name: "future date", header: time.Now().Add(time.Hour).UTC().Format(http.TimeFormat), min: 59 * time.Minute, max: time.Hour},
+		{name: "past date", header: time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), min: 0, max: 0},
+		{name: "garbage", header: "soon", min: 0, max: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{Header: http.Header{}}
+			if tt.header != "" {
+				resp.Header.Set("Retry-After", tt.header)
+			}
+
+			if got := parseRetryAfter(resp); got < tt.min || got > tt.max {
+				t.Errorf("parseRetryAfter(%q) = %s, want in [%s, %s]", tt.header, got, tt.min, tt.max)
+			}
+		})
+	}
+
+	if got := parseRetryAfter(nil); got != 0 {
+		t.Errorf("parseRetryAfter(nil) = %s, want 0", got)
+	}
+}
@@ -0,0 +1,302 @@
+package fcm
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"time"
+
+	"firebase.google.com/go/v4/messaging"
+)
+
+// MaxTTL is the longest lifetime FCM accepts for a message.
+const MaxTTL = 28 * 24 * time.Hour
+
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// webpushTopicPattern is what the Web Push Topic header allows.
+var webpushTopicPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,32}$`)
+
+// Payload is the content of a push message. The platform options are
+// optional and only override what they set.
+type Payload struct {
+	Title    string
+	Body     string
+	Data     map[string]string
+	Priority string
+	// DataOnly sends Data as a background message with no notification to
+	// display. Title, Body, Priority and the platform options are ignored.
+	DataOnly bool
+	// ExpiresAt is when FCM and the platforms should stop trying to deliver
+	// the message. Each send uses the lifetime left at that moment.
+	ExpiresAt *time.Time
+	// CollapseKey lets a newer message replace an undelivered older one with
+	// the same key.
+	CollapseKey string
+	Android     *AndroidOptions
+	APNS        *APNSOptions
+	Webpush     *WebpushOptions
+}
+
+// AndroidOptions are the Android notification settings of a message.
+type AndroidOptions struct {
+	ChannelID         string `json:"channel_id,omitempty"`
+	Sound             string `json:"sound,omitempty"`
+	Icon              string `json:"icon,omitempty"`
+	Color             string `json:"color,omitempty"`
+	Tag               string `json:"tag,omitempty"`
+	ClickAction       string `json:"click_action,omitempty"`
+	Image             string `json:"image,omitempty"`
+	NotificationCount *int   `json:"notification_count,omitempty"`
+	// Visibility is one of private, public or secret.
+	Visibility string `json:"visibility,omitempty"`
+}
+
+// APNSOptions are the aps settings of a message sent to Apple devices.
+type APNSOptions struct {
+	Sound          string `json:"sound,omitempty"`
+	Badge          *int   `json:"badge,omitempty"`
+	Category       string `json:"category,omitempty"`
+	ThreadID       string `json:"thread_id,omitempty"`
+	MutableContent bool   `json:"mutable_content,omitempty"`
+	// InterruptionLevel is one of passive, active, time-sensitive or critical.
+	InterruptionLevel string `json:"interruption_level,omitempty"`
+	// RelevanceScore ranks the notification in a summary, from 0 to 1.
+	RelevanceScore *float64 `json:"relevance_score,omitempty"`
+}
+
+// WebpushOptions are the settings of a message shown by a browser.
+type WebpushOptions struct {
+	Icon    string          `json:"icon,omitempty"`
+	Actions []WebpushAction `json:"actions,omitempty"`
+	// Link is the HTTPS URL opened when the notification is clicked.
+	Link               string `json:"link,omitempty"`
+	RequireInteraction bool   `json:"require_interaction,omitempty"`
+}
+
+type WebpushAction struct {
+	Action string `json:"action"`
+	Title  string `json:"title"`
+	Icon   string `json:"icon,omitempty"`
+}
+
+var androidVisibilities = map[string]messaging.AndroidNotificationVisibility{
+	"private": messaging.VisibilityPrivate,
+	"public":  messaging.VisibilityPublic,
+	"secret":  messaging.VisibilitySecret,
+}
+
+var interruptionLevels = map[string]bool{
+	"passive":        true,
+	"active":         true,
+	"time-sensitive": true,
+	"critical":       true,
+}
+
+func (o *AndroidOptions) Validate() error {
+	if o.Color != "" && !colorPattern.MatchString(o.Color) {
+		return fmt.Errorf("android color must be in #rrggbb format")
+	}
+	if o.Image != "" {
+		if _, err := url.ParseRequestURI(o.Image); err != nil {
+			return fmt.Errorf("android image must be a URL")
+		}
+	}
+	if o.NotificationCount != nil && *o.NotificationCount < 0 {
+		return fmt.Errorf("android notification_count must not be negative")
+	}
+	if _, ok := androidVisibilities[o.Visibility]; o.Visibility != "" && !ok {
+		return fmt.Errorf("android visibility must be one of private, public, secret")
+	}
+	return nil
+}
+
+func (o *APNSOptions) Validate() error {
+	if o.Badge != nil && *o.Badge < 0 {
+		return fmt.Errorf("apns badge must not be negative")
+	}
+	if o.InterruptionLevel != "" && !interruptionLevels[o.InterruptionLevel] {
+		return fmt.Errorf("apns interruption_level must be one of passive, active, time-sensitive, critical")
+	}
+	if o.RelevanceScore != nil && (*o.RelevanceScore < 0 || *o.RelevanceScore > 1) {
+		return fmt.Errorf("apns relevance_score must be between 0 and 1")
+	}
+	return nil
+}
+
+func (o *WebpushOptions) Validate() error {
+	if o.Link != "" {
+		link, err := url.ParseRequestURI(o.Link)
+		if err != nil || link.Scheme != "https" {
+			return fmt.Errorf("webpush link must be an https URL")
+		}
+	}
+	for _, action := range o.Actions {
+		if action.Action == "" || action.Title == "" {
+			return fmt.Errorf("webpush actions need both action and title")
+		}
+	}
+	return nil
+}
+
+// Validate checks the platform options that will be sent with the message.
+func (p Payload) Validate() error {
+	if p.DataOnly {
+		return nil
+	}
+	if p.Android != nil {
+		if err := p.Android.Validate(); err != nil {
+			return err
+		}
+	}
+	if p.APNS != nil {
+		if err := p.APNS.Validate(); err != nil {
+			return err
+		}
+	}
+	if p.Webpush != nil {
+		if err := p.Webpush.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ttl returns the lifetime the message has left, or nil if it has no expiry.
+func (p Payload) ttl() *time.Duration {
+	if p.ExpiresAt == nil {
+		return nil
+	}
+	ttl := max(time.Until(*p.ExpiresAt).Truncate(time.Second), 0)
+	return &ttl
+}
+
+func (p Payload) android() *messaging.AndroidConfig {
+	config := &messaging.AndroidConfig{
+		TTL:         p.ttl(),
+		CollapseKey: p.CollapseKey,
+	}
+
+	switch {
+	case p.DataOnly:
+		config.Priority = "normal"
+	case p.Priority == "high":
+		config.Priority = "high"
+	}
+
+	if o := p.Android; o != nil && !p.DataOnly {
+		config.Notification = &messaging.AndroidNotification{
+			ChannelID:         o.ChannelID,
+			Sound:             o.Sound,
+			Icon:              o.Icon,
+			Color:             o.Color,
+			Tag:               o.Tag,
+			ClickAction:       o.ClickAction,
+			ImageURL:          o.Image,
+			NotificationCount: o.NotificationCount,
+			Visibility:        androidVisibilities[o.Visibility],
+		}
+	}
+
+	if config.Priority == "" && config.Notification == nil && config.TTL == nil && config.CollapseKey == "" {
+		return nil
+	}
+	return config
+}
+
+func (p Payload) apns() *messaging.APNSConfig {
+	headers := map[string]string{}
+	var aps *messaging.Aps
+
+	switch {
+	case p.DataOnly:
+		// Apple only delivers background pushes with push type background and priority 5.
+		headers["apns-push-type"] = "background"
+		headers["apns-priority"] = "5"
+		aps = &messaging.Aps{ContentAvailable: true}
+	case p.Priority == "high":
+		headers["apns-priority"] = "10"
+	}
+
+	if o := p.APNS; o != nil && !p.DataOnly {
+		aps = &messaging.Aps{
+			Sound:          o.Sound,
+			Badge:          o.Badge,
+			Category:       o.Category,
+			ThreadID:       o.ThreadID,
+			MutableContent: o.MutableContent,
+		}
+
+		// The SDK has no fields for these newer aps keys.
+		if o.InterruptionLevel != "" || o.RelevanceScore != nil {
+			aps.CustomData = map[string]interface{}{}
+			if o.InterruptionLevel != "" {
+				aps.CustomData["interruption-level"] = o.InterruptionLevel
+			}
+			if o.RelevanceScore != nil {
+				aps.CustomData["relevance-score"] = *o.RelevanceScore
+			}
+		}
+	}
+
+	if p.ExpiresAt != nil {
+		headers["apns-expiration"] = strconv.FormatInt(p.ExpiresAt.Unix(), 10)
+	}
+	if p.CollapseKey != "" {
+		headers["apns-collapse-id"] = p.CollapseKey
+	}
+
+	if len(headers) == 0 && aps == nil {
+		return nil
+	}
+
+	config := &messaging.APNSConfig{}
+	if len(headers) > 0 {
+		config.Headers = headers
+	}
+	if aps != nil {
+		config.Payload = &messaging.APNSPayload{Aps: aps}
+	}
+	return config
+}
+
+func (p Payload) webpush() *messaging.WebpushConfig {
+	config := &messaging.WebpushConfig{}
+
+	if o := p.Webpush; o != nil && !p.DataOnly {
+		config.Notification = &messaging.WebpushNotification{
+			Icon:               o.Icon,
+			RequireInteraction: o.RequireInteraction,
+		}
+
+		for _, action := range o.Actions {
+			config.Notification.Actions = append(config.Notification.Actions, &messaging.WebpushNotificationAction{
+				Action: action.Action,
+				Title:  action.Title,
+				Icon:   action.Icon,
+			})
+		}
+
+		if o.Link != "" {
+			config.FCMOptions = &messaging.WebpushFCMOptions{Link: o.Link}
+		}
+	}
+
+	headers := map[string]string{}
+	if ttl := p.ttl(); ttl != nil {
+		headers["TTL"] = strconv.FormatInt(int64(ttl.Seconds()), 10)
+	}
+	// Browsers only collapse by keys that fit the Topic header.
+	if webpushTopicPattern.MatchString(p.CollapseKey) {
+		headers["Topic"] = p.CollapseKey
+	}
+	if len(headers) > 0 {
+		config.Headers = headers
+	}
+
+	if config.Notification == nil && config.Headers == nil {
+		return nil
+	}
+	return config
+}
@@ -0,0 +1,238 @@
+package fcm
+
+import (
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"firebase.google.com/go/v4/messaging"
+)
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func floatPtr(v float64) *float64 {
+	return &v
+}
+
+func durationPtr(v time.Duration) *time.Duration {
+	return &v
+}
+
+func TestPayloadAndroid(t *testing.T) {
+	// The extra half second keeps the truncated ttl at a whole hour.
+	expiresAt := time.Now().Add(time.Hour + 500*time.Millisecond)
+	expired := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name    string
+		payload Payload
+		want    *messaging.AndroidConfig
+	}{
+		{name: "normal priority without options", payload: Payload{Priority: "normal"}, want: nil},
+		{
+			name:    "high priority",
+			payload: Payload{Priority: "high"},
+			want:    &messaging.AndroidConfig{Priority: "high"},
+		},
+		{
+			name: "notification options",
+			payload: Payload{Android: &AndroidOptions{
+				ChannelID:         "orders",
+				Sound:             "ding",
+				Icon:              "ic_order",
+				Color:             "#ff0000",
+				Tag:               "order-1",
+				ClickAction:       "OPEN_ORDER",
+				Image:             "https://example.com/order.png",
+				NotificationCount: intPtr(3),
+				Visibility:        "secret",
+			}},
+			want: &messaging.AndroidConfig{Notification: &messaging.AndroidNotification{
+				ChannelID:         "orders",
+				Sound:             "ding",
+				Icon:              "ic_order",
+				Color:             "#ff0000",
+				Tag:               "order-1",
+				ClickAction:       "OPEN_ORDER",
+				ImageURL:          "https://example.com/order.png",
+				NotificationCount: intPtr(3),
+				Visibility:        messaging.VisibilitySecret,
+			}},
+		},
+		{
+			name:    "data-only is sent with normal priority",
+			payload: Payload{DataOnly: true, Priority: "high"},
+			want:    &messaging.AndroidConfig{Priority: "normal"},
+		},
+		{
+			name:    "expiry and collapse key",
+			payload: Payload{ExpiresAt: &expiresAt, CollapseKey: "ride_12345"},
+			want:    &messaging.AndroidConfig{TTL: durationPtr(time.Hour), CollapseKey: "ride_12345"},
+		},
+		{
+			name:    "expired message has zero ttl",
+			payload: Payload{ExpiresAt: &expired},
+			want:    &messaging.AndroidConfig{TTL: durationPtr(0)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.payload.android(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("android() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPayloadAPNS(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour)
+	expiration := strconv.FormatInt(expiresAt.Unix(), 10)
+
+	tests := []struct {
+		name    string
+		payload Payload
+		want    *messaging.APNSConfig
+	}{
+		{name: "normal priority without options", payload: Payload{Priority: "normal"}, want: nil},
+		{
+			name:    "high priority",
+			payload: Payload{Priority: "high"},
+			want:    &messaging.APNSConfig{Headers: map[string]string{"apns-priority": "10"}},
+		},
+		{
+			name: "aps options",
+			payload: Payload{APNS: &APNSOptions{
+				Sound:          "default",
+				Badge:          intPtr(2),
+				Category:       "ORDER",
+				ThreadID:       "orders",
+				MutableContent: true,
+			}},
+			want: &messaging.APNSConfig{Payload: &messaging.APNSPayload{Aps: &messaging.Aps{
+				Sound:          "default",
+				Badge:          intPtr(2),
+				Category:       "ORDER",
+				ThreadID:       "orders",
+				MutableContent: true,
+			}}},
+		},
+		{
+			name:    "newer aps keys go to custom data",
+			payload: Payload{APNS: &APNSOptions{InterruptionLevel: "time-sensitive", RelevanceScore: floatPtr(0.5)}},
+			want: &messaging.APNSConfig{Payload: &messaging.APNSPayload{Aps: &messaging.Aps{
+				CustomData: map[string]interface{}{
+					"interruption-level": "time-sensitive",
+					"relevance-score":    0.5,
+				},
+			}}},
+		},
+		{
+			name:    "data-only is a background push",
+			payload: Payload{DataOnly: true, Priority: "high"},
+			want: &messaging.APNSConfig{
+				Headers: map[string]string{
+					"apns-push-type": "background",
+					"apns-priority":  "5",
+				},
+				Payload: &messaging.APNSPayload{Aps: &messaging.Aps{ContentAvailable: true}},
+			},
+		},
+		{
+			name:    "expiry and collapse key",
+			payload: Payload{ExpiresAt: &expiresAt, CollapseKey: "ride_12345"},
+			want: &messaging.APNSConfig{Headers: map[string]string{
+				"apns-expiration":  expiration,
+				"apns-collapse-id": "ride_12345",
+			}},
+		},
+		{
+			name:    "data-only keeps expiry and collapse key",
+			payload: Payload{DataOnly: true, ExpiresAt: &expiresAt, CollapseKey: "ride_12345"},
+			want: &messaging.APNSConfig{
+				Headers: map[string]string{
+					"apns-push-type":   "background",
+					"apns-priority":    "5",
+					"apns-expiration":  expiration,
+					"apns-collapse-id": "ride_12345",
+				},
+				Payload: &messaging.APNSPayload{Aps: &messaging.Aps{ContentAvailable: true}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.payload.apns(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("apns() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPayloadWebpush(t *testing.T) {
+	expiresAt := time.Now().Add(time.Hour + 500*time.Millisecond)
+
+	tests := []struct {
+		name    string
+		payload Payload
+		want    *messaging.WebpushConfig
+	}{
+		{name: "no options", payload: Payload{Priority: "high"}, want: nil},
+		{
+			name: "notification options",
+			payload: Payload{Webpush: &WebpushOptions{
+				Icon:               "https://example.com/icon.png",
+				Actions:            []WebpushAction{{Action: "open", Title: "Open", Icon: "https://example.com/open.png"}},
+				Link:               "https://example.com/orders/1",
+				RequireInteraction: true,
+			}},
+			want: &messaging.WebpushConfig{
+				Notification: &messaging.WebpushNotification{
+					Icon:               "https://example.com/icon.png",
+					RequireInteraction: true,
+					Actions: []*messaging.WebpushNotificationAction{
+						{Action: "open", Title: "Open", Icon: "https://example.com/open.png"},
+					},
+				},
+				FCMOptions: &messaging.WebpushFCMOptions{Link: "https://example.com/orders/1"},
+			},
+		},
+		{
+			name:    "no link",
+			payload: Payload{Webpush: &WebpushOptions{Icon: "https://example.com/icon.png"}},
+			want: &messaging.WebpushConfig{
+				Notification: &messaging.WebpushNotification{Icon: "https://example.com/icon.png"},
+			},
+		},
+		{
+			name:    "data-only has no notification",
+			payload: Payload{DataOnly: true, Webpush: &WebpushOptions{Icon: "https://example.com/icon.png"}},
+			want:    nil,
+		},
+		{
+			name:    "expiry and collapse key",
+			payload: Payload{ExpiresAt: &expiresAt, CollapseKey: "ride_12345"},
+			want: &messaging.WebpushConfig{Headers: map[string]string{
+				"TTL":   "3600",
+				"Topic": "ride_12345",
+			}},
+		},
+		{
+			name:    "collapse key that does not fit the topic header",
+			payload: Payload{CollapseKey: "ride 12345"},
+			want:    nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.payload.webpush(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("webpush() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,191 @@
+package fcm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	messagingScope  = "https://www.googleapis.com/auth/firebase.messaging"
+	sendURLTemplate = "https://fcm.googleapis.com/v1/projects/%s/messages:send"
+
+	// maxRawMessageSize bounds a raw message stored on a task. FCM itself
+	// rejects payloads over 4 KB, but headers and options add to that.
+	maxRawMessageSize = 32 * 1024
+)
+
+// rawMessage is the part of an FCM HTTP v1 message checked before queueing.
+type rawMessage struct {
+	Token     *string           `json:"token"`
+	Topic     *string           `json:"topic"`
+	Condition *string           `json:"condition"`
+	Data      map[string]string `json:"data"`
+}
+
+// ValidateRawMessage checks that raw is an FCM HTTP v1 message object, the
+// "message" of a send request, with exactly one target, and returns that
+// target. The rest of the message is left for FCM to validate.
+func ValidateRawMessage(raw []byte) (Target, error) {
+	if len(raw) > maxRawMessageSize {
+		return Target{}, fmt.Errorf("message is larger than %d bytes", maxRawMessageSize)
+	}
+
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || raw[0] != '{' {
+		return Target{}, fmt.Errorf("message must be a JSON object")
+	}
+
+	var message rawMessage
+	if err := json.Unmarshal(raw, &message); err != nil {
+		return Target{}, fmt.Errorf("invalid message: %w", err)
+	}
+
+	var target Target
+	targets := 0
+	if message.Token != nil {
+		target.Token = *message.Token
+		targets++
+	}
+	if message.Topic != nil {
+		target.Topic = *message.Topic
+		targets++
+	}
+	if message.Condition != nil {
+		target.Condition = *message.Condition
+		targets++
+	}
+	if targets != 1 {
+		return Target{}, fmt.Errorf("message must have exactly one of token, topic or condition")
+	}
+
+	switch {
+	case message.Token != nil:
+		if target.Token == "" {
+			return Target{}, fmt.Errorf("message token must not be empty")
+		}
+	case message.Topic != nil:
+		// The message is sent as given, so the SDK's prefix stripping does not apply.
+		name, err := NormalizeTopic(target.Topic)
+		if err != nil {
+			return Target{}, err
+		}
+		if name != target.Topic {
+			return Target{}, fmt.Errorf("message topic must be given without the /topics/ prefix")
+		}
+	case message.Condition != nil:
+		if err := ValidateCondition(target.Condition); err != nil {
+			return Target{}, err
+		}
+	}
+
+	return target, nil
+}
+
+// SendRaw sends an FCM HTTP v1 message object exactly as given, bypassing the
+// SDK's message model. A returned error is an *Error.
+func (c *Client) SendRaw(ctx context.Context, message json.RawMessage) (string, error) {
+	body, err := json.Marshal(map[string]json.RawMessage{"message": message})
+	if err != nil {
+		return "", c.fail(ctx, &Error{Code: ErrorCodeInvalidArgument, Err: fmt.Errorf("error encoding message: %w", err)})
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.sendURL, bytes.NewReader(body))
+	if err != nil {
+		return "", c.fail(ctx, &Error{Code: ErrorCodeUnknown, Err: fmt.Errorf("error sending message: %w", err)})
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", c.fail(ctx, &Error{Code: ErrorCodeUnknown, Err: fmt.Errorf("error sending message: %w", err)})
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", c.fail(ctx, &Error{Code: ErrorCodeUnknown, Err: fmt.Errorf("error reading response: %w", err)})
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", c.fail(ctx, newRawError(resp, respBody))
+	}
+
+	var result struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return "", c.fail(ctx, &Error{Code: ErrorCodeUnknown, Err: fmt.Errorf("error decoding response: %w", err)})
+	}
+
+	c.record(ctx, nil)
+	return result.Name, nil
+}
+
+func (c *Client) fail(ctx context.Context, err *Error) *Error {
+	c.record(ctx, err)
+	return err
+}
+
+// rawErrorResponse is the error body of the FCM HTTP v1 API.
+type rawErrorResponse struct {
+	Error struct {
+		Message string `json:"message"`
+		Status  string `json:"status"`
+		Details []struct {
+			Type      string `json:"@type"`
+			ErrorCode string `json:"errorCode"`
+		} `json:"details"`
+	} `json:"error"`
+}
+
+// newRawError classifies a failed HTTP v1 send the way ClassifyError does
+// for the SDK: by the FCM error code in the details, then by the status.
+func newRawError(resp *http.Response, body []byte) *Error {
+	var parsed rawErrorResponse
+	_ = json.Unmarshal(body, &parsed)
+
+	code := ErrorCodeUnknown
+	for _, detail := range parsed.Error.Details {
+		if strings.HasSuffix(detail.Type, "google.firebase.fcm.v1.FcmError") && detail.ErrorCode != "" {
+			code = fcmErrorCode(detail.ErrorCode)
+		}
+	}
+	if code == ErrorCodeUnknown {
+		switch parsed.Error.Status {
+		case "INVALID_ARGUMENT":
+			code = ErrorCodeInvalidArgument
+		case "RESOURCE_EXHAUSTED":
+			code = ErrorCodeQuotaExceeded
+		case "UNAVAILABLE":
+			code = ErrorCodeUnavailable
+		case "INTERNAL":
+			code = ErrorCodeInternal
+		}
+	}
+
+	message := parsed.Error.Message
+	if message == "" {
+		message = strings.TrimSpace(string(body))
+	}
+
+	return &Error{
+		Code:       code,
+		RetryAfter: parseRetryAfter(resp),
+		Err:        fmt.Errorf("error sending message: http %d: %s", resp.StatusCode, message),
+	}
+}
+
+func fcmErrorCode(code string) ErrorCode {
+	switch ErrorCode(code) {
+	case ErrorCodeUnregistered, ErrorCodeInvalidArgument, ErrorCodeSenderIDMismatch, ErrorCodeThirdPartyAuth,
+		ErrorCodeQuotaExceeded, ErrorCodeUnavailable, ErrorCodeInternal:
+		return ErrorCode(code)
+	default:
+		return ErrorCodeUnknown
+	}
+}
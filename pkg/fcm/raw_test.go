@@ -0,0 +1,63 @@
+package fcm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateRawMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    Target
+		wantErr bool
+	}{
+		{
+			name: "token",
+			raw:  `{"token": "device_token", "notification": {"title": "t"}}`,
+			want: Target{Token: "device_token"},
+		},
+		{
+			name: "topic",
+			raw:  `{"topic": "news", "data": {"k": "v"}}`,
+			want: Target{Topic: "news"},
+		},
+		{
+			name: "condition",
+			raw:  `{"condition": "'news' in topics && 'sport' in topics"}`,
+			want: Target{Condition: "'news' in topics && 'sport' in topics"},
+		},
+		{
+			name: "leading whitespace",
+			raw:  "\n  {\"token\": \"device_token\"}",
+			want: Target{Token: "device_token"},
+		},
+		{name: "not an object", raw: `["token"]`, wantErr: true},
+		{name: "empty", raw: ``, wantErr: true},
+		{name: "malformed JSON", raw: `{"token": "device_token"`, wantErr: true},
+		{name: "no target", raw: `{"notification": {"title": "t"}}`, wantErr: true},
+		{name: "two targets", raw: `{"token": "device_token", "topic": "news"}`, wantErr: true},
+		{name: "empty token", raw: `{"token": ""}`, wantErr: true},
+		{name: "topic with prefix", raw: `{"topic": "/topics/news"}`, wantErr: true},
+		{name: "invalid topic", raw: `{"topic": "news!"}`, wantErr: true},
+		{name: "invalid condition", raw: `{"condition": "news in topics"}`, wantErr: true},
+		{name: "non-string data value", raw: `{"token": "device_token", "data": {"count": 1}}`, wantErr: true},
+		{
+			name:    "too large",
+			raw:     `{"token": "device_token", "data": {"k": "` + strings.Repeat("x", maxRawMessageSize) + `"}}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ValidateRawMessage([]byte(tt.raw))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateRawMessage() error = %v, want error: %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ValidateRawMessage() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package fcm
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"firebase.google.com/go/v4/messaging"
+)
+
+// MaxConditionTopics is the most topics FCM allows in one condition.
+const MaxConditionTopics = 5
+
+var topicNamePattern = regexp.MustCompile(`^[a-zA-Z0-9\-_.~%]+$`)
+
+// Target addresses a message to exactly one of a registration token, a topic
+// or a topic condition.
+type Target struct {
+	Token     string
+	Topic     string
+	Condition string
+}
+
+// Validate checks that t sets exactly one well-formed target.
+func (t Target) Validate() error {
+	targets := 0
+	for _, target := range []string{t.Token, t.Topic, t.Condition} {
+		if target != "" {
+			targets++
+		}
+	}
+	if targets != 1 {
+		return fmt.Errorf("exactly one of token, topic or condition must be set")
+	}
+
+	if t.Topic != "" {
+		if _, err := NormalizeTopic(t.Topic); err != nil {
+			return err
+		}
+	}
+	if t.Condition != "" {
+		return ValidateCondition(t.Condition)
+	}
+	return nil
+}
+
+func (t Target) apply(message *messaging.Message) {
+	message.Token = t.Token
+	message.Topic = t.Topic
+	message.Condition = t.Condition
+}
+
+// NormalizeTopic strips the optional "/topics/" prefix from topic and checks
+// that the rest is a valid FCM topic name.
+func NormalizeTopic(topic string) (string, error) {
+	name := strings.TrimPrefix(topic, "/topics/")
+	if !topicNamePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid topic name %q", topic)
+	}
+	return name, nil
+}
+
+// ValidateCondition checks a topic condition such as
+// "'news' in topics && ('sport' in topics || 'weather' in topics)": topics
+// joined by && and ||, optionally grouped with parentheses, and no more than
+// MaxConditionTopics of them.
+func ValidateCondition(condition string) error {
+	p := &conditionParser{input: condition}
+	if err := p.parseExpression(); err != nil {
+		return fmt.Errorf("invalid condition: %w", err)
+	}
+
+	p.skipSpaces()
+	if p.pos < len(p.input) {
+		return fmt.Errorf("invalid condition: unexpected %q at position %d", p.input[p.pos:], p.pos)
+	}
+	if p.topics > MaxConditionTopics {
+		return fmt.Errorf("invalid condition: %d topics, at most %d are allowed", p.topics, MaxConditionTopics)
+	}
+
+	return nil
+}
+
+type conditionParser struct {
+	input  string
+	pos    int
+	topics int
+}
+
+// parseExpression parses: term (("&&" | "||") term)*
+func (p *conditionParser) parseExpression() error {
+	if err := p.parseTerm(); err != nil {
+		return err
+	}
+
+	for {
+		p.skipSpaces()
+		rest := p.input[p.pos:]
+		if !strings.HasPrefix(rest, "&&") && !strings.HasPrefix(rest, "||") {
+			return nil
+		}
+		p.pos += 2
+
+		if err := p.parseTerm(); err != nil {
+			return err
+		}
+	}
+}
+
+// parseTerm parses: "(" expression ")" | quoted-topic "in" "topics"
+func (p *conditionParser) parseTerm() error {
+	p.skipSpaces()
+	if p.pos >= len(p.input) {
+		return fmt.Errorf("unexpected end of condition")
+	}
+
+	if p.input[p.pos] == '(' {
+		p.pos++
+		if err := p.parseExpression(); err != nil {
+			return err
+		}
+		p.skipSpaces()
+		if p.pos >= len(p.input) || p.input[p.pos] != ')' {
+			return fmt.Errorf("missing ')' at position %d", p.pos)
+		}
+		p.pos++
+		return nil
+	}
+
+	quote := p.input[p.pos]
+	if quote != '\'' && quote != '"' {
+		return fmt.Errorf("expected a quoted topic or '(' at position %d", p.pos)
+	}
+	end := strings.IndexByte(p.input[p.pos+1:], quote)
+	if end < 0 {
+		return fmt.Errorf("unterminated topic name at position %d", p.pos)
+	}
+	name := p.input[p.pos+1 : p.pos+1+end]
+	if !topicNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid topic name %q", name)
+	}
+	p.pos += end + 2
+	p.topics++
+
+	if err := p.expectWord("in"); err != nil {
+		return err
+	}
+	return p.expectWord("topics")
+}
+
+func (p *conditionParser) expectWord(word string) error {
+	p.skipSpaces()
+	if !strings.HasPrefix(p.input[p.pos:], word) {
+		return fmt.Errorf("expected %q at position %d", word, p.pos)
+	}
+
+	next := p.pos + len(word)
+	if next < len(p.input) && isWordChar(p.input[next]) {
+		return fmt.Errorf("expected %q at position %d", word, p.pos)
+	}
+	p.pos = next
+	return nil
+}
+
+func (p *conditionParser) skipSpaces() {
+	for p.pos < len(p.input) && (p.input[p.pos] == ' ' || p.input[p.pos] == '\t' || p.input[p.pos] == '\n') {
+		p.pos++
+	}
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
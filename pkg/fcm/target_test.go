@@ -0,0 +1,40 @@
+package fcm
+
+import "testing"
+
+func TestValidateCondition(t *testing.T) {
+	tests := []struct {
+		name      string
+		condition string
+		wantErr   bool
+	}{
+		{name: "single topic", condition: "'news' in topics"},
+		{name: "double quotes", condition: `"news" in topics`},
+		{name: "and", condition: "'news' in topics && 'sport' in topics"},
+		{name: "grouped or", condition: "'news' in topics && ('sport' in topics || 'weather' in topics)"},
+		{name: "nested groups", condition: "(('a' in topics))"},
+		{name: "extra whitespace", condition: "  'a' in topics\n&&\t'b' in topics  "},
+		{name: "five topics", condition: "'a' in topics || 'b' in topics || 'c' in topics || 'd' in topics || 'e' in topics"},
+		{name: "six topics", condition: "'a' in topics || 'b' in topics || 'c' in topics || 'd' in topics || 'e' in topics || 'f' in topics", wantErr: true},
+		{name: "empty", condition: "", wantErr: true},
+		{name: "unquoted topic", condition: "news in topics", wantErr: true},
+		{name: "unterminated topic", condition: "'news in topics", wantErr: true},
+		{name: "invalid topic name", condition: "'news!' in topics", wantErr: true},
+		{name: "missing in", condition: "'news' topics", wantErr: true},
+		{name: "word prefix only", condition: "'news' in topicsx", wantErr: true},
+		{name: "dangling operator", condition: "'news' in topics &&", wantErr: true},
+		{name: "single ampersand", condition: "'news' in topics & 'sport' in topics", wantErr: true},
+		{name: "missing closing parenthesis", condition: "('news' in topics", wantErr: true},
+		{name: "trailing input", condition: "'news' in topics)", wantErr: true},
+		{name: "negation is not supported", condition: "!('news' in topics)", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateCondition(tt.condition)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateCondition(%q) = %v, want error: %v", tt.condition, err, tt.wantErr)
+			}
+		})
+	}
+}